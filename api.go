@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// apiShutdownTimeout bounds how long requests in flight may take when the API
+// server is stopped.
+const apiShutdownTimeout = 5 * time.Second
+
+// maxOverrideMinutes is the longest override the API accepts, a week.
+const maxOverrideMinutes = 7 * 24 * 60
+
+// apiServer serves the local control API while it is configured.
+type apiServer struct {
+	mu       sync.Mutex
+	settings APIConfig
+	server   *http.Server
+}
+
+var api = &apiServer{}
+
+// apply starts, restarts or stops the server to match the settings.
+func (a *apiServer) apply(settings APIConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.server != nil && settings == a.settings {
+		return
+	}
+	a.stopLocked()
+	a.settings = settings
+	if settings.Listen == "" {
+		return
+	}
+
+	token, err := resolveToken(settings.Token)
+	if err != nil {
+		fmt.Printf("Not starting the API: %s\n", err)
+		return
+	}
+	ln, err := listen(settings.Listen)
+	if err != nil {
+		fmt.Printf("Not starting the API: %s\n", err)
+		return
+	}
+
+	server := &http.Server{Handler: newAPIHandler(token)}
+	a.server = server
+	go func() {
+		if err := server.Serve(ln); err != http.ErrServerClosed {
+			fmt.Printf("API server stopped: %s\n", err)
+		}
+	}()
+	fmt.Printf("API listening on %s\n", settings.Listen)
+}
+
+// stop stops the server, if it is running.
+func (a *apiServer) stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopLocked()
+}
+
+func (a *apiServer) stopLocked() {
+	if a.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+	defer cancel()
+	if err := a.server.Shutdown(ctx); err != nil {
+		fmt.Printf("Could not stop the API server cleanly: %s\n", err)
+	}
+	a.server = nil
+}
+
+// listen listens on a TCP address, or on a Unix socket for "unix:PATH". The
+// socket is only accessible to the current user.
+func listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, "unix:") {
+		return net.Listen("tcp", addr)
+	}
+
+	path, err := homedir.Expand(strings.TrimPrefix(addr, "unix:"))
+	if err != nil {
+		return nil, err
+	}
+	// Remove a socket left behind by an earlier run, but nothing else.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func newAPIHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.Handle("/state", requireToken(token, handleState))
+	mux.Handle("/override", requireToken(token, handleOverride))
+	mux.Handle("/refresh", requireToken(token, handleRefresh))
+	return mux
+}
+
+// requireToken only lets requests through that have the API token as a
+// bearer token.
+func requireToken(token string, next http.HandlerFunc) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or wrong token")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// apiState is the response of GET /state.
+type apiState struct {
+	State string `json:"state"`
+	// Summary and End describe the meeting, if a calendar reported it.
+	Summary   string         `json:"summary,omitempty"`
+	End       *time.Time     `json:"end,omitempty"`
+	Detected  []apiDetection `json:"detected"`
+	Override  *override      `json:"override"`
+	CheckedAt time.Time      `json:"checkedAt"`
+	Accounts  []apiAccount   `json:"accounts"`
+}
+
+type apiDetection struct {
+	State    string `json:"state"`
+	Detector string `json:"detector"`
+	Process  string `json:"process,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type apiAccount struct {
+	Name        string     `json:"name"`
+	Team        string     `json:"team,omitempty"`
+	Healthy     bool       `json:"healthy"`
+	HealthError string     `json:"healthError,omitempty"`
+	LastUpdate  *apiResult `json:"lastUpdate,omitempty"`
+}
+
+type apiResult struct {
+	State     string       `json:"state"`
+	Status    *SlackStatus `json:"status,omitempty"`
+	OK        bool         `json:"ok"`
+	Warnings  []string     `json:"warnings,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	LatencyMS int64        `json:"latencyMs"`
+	At        time.Time    `json:"at"`
+}
+
+// currentState gathers the outcome of the last check and of each account's
+// last update.
+func currentState() apiState {
+	detected, current, at := control.last()
+	st := apiState{
+		State:     current.State,
+		Summary:   current.Meeting.Summary,
+		CheckedAt: at,
+		Accounts:  []apiAccount{},
+	}
+	if end := current.Meeting.End; !end.IsZero() {
+		st.End = &end
+	}
+	if o, ok := control.activeOverride(time.Now()); ok {
+		st.Override = &o
+	}
+	st.Detected = detections(detected)
+
+	for _, account := range config.get().Accounts {
+		h := health.get(account.Name)
+		a := apiAccount{Name: account.Name, Team: h.Team, Healthy: h.Checked && h.Err == nil}
+		if h.Err != nil {
+			a.HealthError = h.Err.Error()
+		}
+		if res, ok := results.get(account.Name); ok {
+			a.LastUpdate = &apiResult{
+				State:     res.State,
+				Status:    res.Status,
+				OK:        res.Err == nil,
+				Warnings:  res.Warnings,
+				LatencyMS: res.Latency.Milliseconds(),
+				At:        res.At,
+			}
+			if res.Err != nil {
+				a.LastUpdate.Error = res.Err.Error()
+			}
+		}
+		st.Accounts = append(st.Accounts, a)
+	}
+	return st
+}
+
+// detections describes the states reported by the detectors.
+func detections(signals []presence.Signal) []apiDetection {
+	ds := make([]apiDetection, 0, len(signals))
+	for _, s := range signals {
+		ds = append(ds, apiDetection{
+			State:    s.State,
+			Detector: s.Meeting.Detector,
+			Process:  s.Meeting.Process,
+			Detail:   s.Meeting.Detail,
+		})
+	}
+	return ds
+}
+
+func handleState(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, currentState())
+}
+
+// overrideRequest is the body of POST /override.
+type overrideRequest struct {
+	State string `json:"state"`
+	// Paused pauses automation instead of forcing a state.
+	Paused  bool `json:"paused"`
+	Minutes int  `json:"minutes"`
+}
+
+func handleOverride(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost, http.MethodDelete) {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if control.clearOverride() {
+			fmt.Println("Override cleared through the API")
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var req overrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	if _, ok := config.get().priorities[req.State]; !ok && !req.Paused {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown state %q", req.State))
+		return
+	}
+	if req.Paused && req.State != "" {
+		writeError(w, http.StatusBadRequest, "state can't be set when pausing")
+		return
+	}
+	if req.Minutes <= 0 || req.Minutes > maxOverrideMinutes {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("minutes must be between 1 and %d", maxOverrideMinutes))
+		return
+	}
+
+	o := override{State: req.State, Paused: req.Paused, Until: time.Now().Add(time.Duration(req.Minutes) * time.Minute)}
+	control.setOverride(o)
+	if o.Paused {
+		fmt.Printf("Automation paused until %s through the API\n", o.until())
+	} else {
+		fmt.Printf("State overridden to %s until %s through the API\n", o.State, o.until())
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	control.refresh()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleHealthz reports whether the loop is still checking for meetings. It
+// doesn't need the token.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	_, _, at := control.last()
+	cfg := config.get()
+	// A check is due every interval, and may take as long as an update.
+	if at.IsZero() || time.Since(at) > 2*cfg.Interval+cfg.UpdateTimeout {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"ok": false, "checkedAt": at})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "checkedAt": at})
+}
+
+// allowMethods replies with 405 Method Not Allowed unless the request uses
+// one of the methods.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("Could not write API response: %s\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+)
+
+const testAPIToken = "secret"
+
+// newTestAPI serves the API for a config without accounts.
+func newTestAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	newTestWorkspace(t)
+	cfg := testConfig()
+	cfg.priorities = presence.Priorities(nil)
+	config.set(cfg)
+
+	server := httptest.NewServer(newAPIHandler(testAPIToken))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// apiRequest sends a request with the given Authorization header, if any,
+// and returns the response with the body read.
+func apiRequest(t *testing.T, server *httptest.Server, method, path, auth, body string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusAccepted {
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("%s %s: could not decode the body: %v", method, path, err)
+		}
+	}
+	return resp, decoded
+}
+
+// woken reports whether the loop was asked to check right away.
+func woken() bool {
+	select {
+	case <-control.wakeups():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestAPIRequiresToken(t *testing.T) {
+	server := newTestAPI(t)
+
+	for _, auth := range []string{"", "Bearer wrong", "Bearer " + testAPIToken + "x", testAPIToken, "Basic " + testAPIToken} {
+		for _, path := range []string{"/state", "/override", "/refresh"} {
+			resp, body := apiRequest(t, server, http.MethodGet, path, auth, "")
+			if resp.StatusCode != http.StatusUnauthorized || body["error"] != "missing or wrong token" {
+				t.Errorf("GET %s with %q = %d %v, want 401", path, auth, resp.StatusCode, body)
+			}
+		}
+	}
+
+	resp, body := apiRequest(t, server, http.MethodGet, "/state", "Bearer "+testAPIToken, "")
+	if resp.StatusCode != http.StatusOK || body["state"] != presence.Available {
+		t.Errorf("GET /state with the token = %d %v, want 200", resp.StatusCode, body)
+	}
+}
+
+func TestAPIHealthz(t *testing.T) {
+	server := newTestAPI(t)
+
+	// No check has run yet.
+	resp, body := apiRequest(t, server, http.MethodGet, "/healthz", "", "")
+	if resp.StatusCode != http.StatusServiceUnavailable || body["ok"] != false {
+		t.Errorf("GET /healthz before a check = %d %v, want 503", resp.StatusCode, body)
+	}
+
+	control.checked(nil, presence.Signal{State: presence.Available}, time.Now())
+	resp, body = apiRequest(t, server, http.MethodGet, "/healthz", "", "")
+	if resp.StatusCode != http.StatusOK || body["ok"] != true {
+		t.Errorf("GET /healthz without a token = %d %v, want 200", resp.StatusCode, body)
+	}
+
+	// The loop has missed two checks.
+	control.checked(nil, presence.Signal{State: presence.Available}, time.Now().Add(-3*time.Minute))
+	resp, _ = apiRequest(t, server, http.MethodGet, "/healthz", "", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET /healthz after missed checks = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestAPIOverrideRejected(t *testing.T) {
+	server := newTestAPI(t)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"unknown state", `{"state": "dnd", "minutes": 30}`, `unknown state "dnd"`},
+		{"no state", `{"minutes": 30}`, `unknown state ""`},
+		{"paused with a state", `{"state": "focus", "paused": true, "minutes": 30}`, "state can't be set when pausing"},
+		{"no minutes", `{"state": "focus"}`, "minutes must be between 1 and 10080"},
+		{"negative minutes", `{"state": "focus", "minutes": -5}`, "minutes must be between 1 and 10080"},
+		{"too many minutes", `{"paused": true, "minutes": 10081}`, "minutes must be between 1 and 10080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := apiRequest(t, server, http.MethodPost, "/override", "Bearer "+testAPIToken, tt.body)
+			if resp.StatusCode != http.StatusBadRequest || body["error"] != tt.want {
+				t.Errorf("POST /override = %d %v, want 400 %q", resp.StatusCode, body, tt.want)
+			}
+			if _, ok := control.activeOverride(time.Now()); ok {
+				t.Error("a rejected request set an override")
+			}
+			if woken() {
+				t.Error("a rejected request woke the loop")
+			}
+		})
+	}
+
+	resp, body := apiRequest(t, server, http.MethodPost, "/override", "Bearer "+testAPIToken, `{"state": `)
+	if resp.StatusCode != http.StatusBadRequest || !strings.HasPrefix(body["error"].(string), "invalid body: ") {
+		t.Errorf("POST /override with a broken body = %d %v, want 400", resp.StatusCode, body)
+	}
+}
+
+func TestAPIOverride(t *testing.T) {
+	server := newTestAPI(t)
+	auth := "Bearer " + testAPIToken
+
+	resp, body := apiRequest(t, server, http.MethodPost, "/override", auth, `{"state": "focus", "minutes": 30}`)
+	if resp.StatusCode != http.StatusOK || body["state"] != presence.Focus {
+		t.Fatalf("POST /override = %d %v, want 200", resp.StatusCode, body)
+	}
+	o, ok := control.activeOverride(time.Now())
+	if !ok || o.State != presence.Focus || o.Until.Before(time.Now().Add(29*time.Minute)) {
+		t.Errorf("override = %+v, want focus for 30 minutes", o)
+	}
+	if !woken() {
+		t.Error("setting an override didn't wake the loop")
+	}
+
+	resp, body = apiRequest(t, server, http.MethodPost, "/override", auth, `{"paused": true, "minutes": 10080}`)
+	if resp.StatusCode != http.StatusOK || body["paused"] != true {
+		t.Fatalf("POST /override pausing = %d %v, want 200", resp.StatusCode, body)
+	}
+	woken()
+
+	resp, _ = apiRequest(t, server, http.MethodDelete, "/override", auth, "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE /override = %d, want 204", resp.StatusCode)
+	}
+	if _, ok := control.activeOverride(time.Now()); ok {
+		t.Error("override still active after DELETE")
+	}
+	if !woken() {
+		t.Error("clearing the override didn't wake the loop")
+	}
+
+	// Clearing again is fine, but there is nothing to apply.
+	resp, _ = apiRequest(t, server, http.MethodDelete, "/override", auth, "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("second DELETE /override = %d, want 204", resp.StatusCode)
+	}
+	if woken() {
+		t.Error("clearing without an override woke the loop")
+	}
+}
+
+func TestAPIRefresh(t *testing.T) {
+	server := newTestAPI(t)
+
+	resp, _ := apiRequest(t, server, http.MethodPost, "/refresh", "Bearer "+testAPIToken, "")
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("POST /refresh = %d, want 202", resp.StatusCode)
+	}
+	if !woken() {
+		t.Error("refresh didn't wake the loop")
+	}
+}
+
+func TestAPIMethodNotAllowed(t *testing.T) {
+	server := newTestAPI(t)
+	auth := "Bearer " + testAPIToken
+
+	tests := []struct {
+		method, path, allow string
+	}{
+		{http.MethodPost, "/state", "GET"},
+		{http.MethodGet, "/override", "POST, DELETE"},
+		{http.MethodPut, "/override", "POST, DELETE"},
+		{http.MethodGet, "/refresh", "POST"},
+		{http.MethodPost, "/healthz", "GET"},
+	}
+	for _, tt := range tests {
+		resp, body := apiRequest(t, server, tt.method, tt.path, auth, "")
+		if resp.StatusCode != http.StatusMethodNotAllowed || body["error"] != "method not allowed" {
+			t.Errorf("%s %s = %d %v, want 405", tt.method, tt.path, resp.StatusCode, body)
+		}
+		if got := resp.Header.Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.allow)
+		}
+	}
+	if woken() {
+		t.Error("a rejected request woke the loop")
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// statusQueryTimeout bounds asking a running instance for its state.
+const statusQueryTimeout = 5 * time.Second
+
+// errUpdatesFailed is returned by commands when some accounts could not be
+// updated. The details have been printed already.
+var errUpdatesFailed = errors.New("some accounts could not be updated")
+
+// setupCommand loads the config and the saved state, for commands that
+// update the accounts.
+func setupCommand() error {
+	if err := loadInConfig(); err != nil {
+		return err
+	}
+	loadState()
+	return nil
+}
+
+// runStatus prints the state of the running instance, through its API, or
+// what the detectors report right now if none can be reached.
+func runStatus(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.API.Listen != "" {
+		st, err := queryState(cfg.API)
+		if err == nil {
+			printState(st)
+			return nil
+		}
+		fmt.Printf("Could not reach the running instance, checking directly: %s\n", err)
+	}
+
+	detected := checkForMeeting(context.Background(), cfg)
+	t := presence.NewEngine(cfg.priorities).Step(detected)
+	printState(apiState{State: t.To.State, Summary: t.To.Meeting.Summary, Detected: detections(detected)})
+	return nil
+}
+
+// runSet sets every account's status for the given state.
+func runSet(args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected the name of a state")
+	}
+	if err := setupCommand(); err != nil {
+		return err
+	}
+	cfg := config.get()
+	if _, ok := cfg.priorities[args[0]]; !ok {
+		return errors.New(unknownState(args[0], cfg.priorities))
+	}
+
+	return forceState(context.Background(), cfg, presence.Signal{State: args[0]})
+}
+
+// runClear sets every account back to its noMeetingStatus.
+func runClear(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	if err := setupCommand(); err != nil {
+		return err
+	}
+
+	return forceState(context.Background(), config.get(), presence.Signal{State: presence.Available})
+}
+
+// forceState writes the status of a state the user asked for to every
+// account, even where the status was set by hand. Disabled accounts are only
+// released, see releaseDisabled, and broken ones make the command fail.
+func forceState(ctx context.Context, cfg *Config, signal presence.Signal) error {
+	fmt.Printf("Setting status to %s\n", signal.State)
+
+	skipped := 0
+	updates.retain(cfg.Accounts)
+	for _, account := range cfg.Accounts {
+		if !account.enabled() {
+			if !releaseDisabled(account) {
+				fmt.Printf("Skipping %s: disabled\n", account.Name)
+			}
+			continue
+		}
+		if h := health.get(account.Name); h.broken() {
+			fmt.Printf("Skipping %s: %s\n", account.Name, h.Err)
+			skipped++
+			continue
+		}
+		updates.force(account.Name, signal)
+	}
+	if !syncAccounts(ctx, cfg) || skipped > 0 {
+		return errUpdatesFailed
+	}
+	return nil
+}
+
+// runOnce checks for meetings once and updates the accounts, for running
+// from cron. Like the tray app, it only changes a status when the state
+// changed since the last run, and otherwise just pushes back its expiration.
+// Pauses and overrides set in the tray app or through the API are obeyed.
+func runOnce(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	if err := setupCommand(); err != nil {
+		return err
+	}
+	cfg := config.get()
+	ctx := context.Background()
+
+	signals := checkForMeeting(ctx, cfg)
+	if o := store.override(); o != nil && time.Now().Before(o.Until) {
+		if o.Paused {
+			fmt.Printf("Automation paused until %s\n", o.until())
+			return nil
+		}
+		fmt.Printf("State overridden to %s until %s\n", o.State, o.until())
+		signals = []presence.Signal{{State: o.State}}
+	}
+
+	t := presence.NewEngine(cfg.priorities).Step(signals)
+	fmt.Printf("Current state is %s\n", t.To.State)
+
+	for _, account := range cfg.Accounts {
+		if health.get(account.Name).broken() {
+			continue
+		}
+		if !account.enabled() {
+			releaseDisabled(account)
+			continue
+		}
+		saved := store.account(account.Name)
+		want := account.statusFor(t.To.State).expand(t.To.Meeting)
+		switch {
+		case saved.State != t.To.State || saved.Written == nil || !want.same(*saved.Written):
+			updates.want(account.Name, t.To, false)
+		case cfg.refreshesExpiration(t.To):
+			updates.want(account.Name, t.To, true)
+		}
+	}
+	if updates.len() == 0 {
+		fmt.Printf("Status already set to %s\n", t.To.State)
+		return nil
+	}
+	if !syncAccounts(ctx, cfg) {
+		return errUpdatesFailed
+	}
+	return nil
+}
+
+// queryState asks the running instance for its state through the API.
+func queryState(settings APIConfig) (apiState, error) {
+	token, err := resolveToken(settings.Token)
+	if err != nil {
+		return apiState{}, err
+	}
+
+	client := &http.Client{Timeout: statusQueryTimeout}
+	base := "http://" + settings.Listen
+	if strings.HasPrefix(settings.Listen, "unix:") {
+		path, err := homedir.Expand(strings.TrimPrefix(settings.Listen, "unix:"))
+		if err != nil {
+			return apiState{}, err
+		}
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		base = "http://unix"
+	}
+
+	req, err := http.NewRequest(http.MethodGet, base+"/state", nil)
+	if err != nil {
+		return apiState{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return apiState{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiState{}, fmt.Errorf("GET /state: %s", resp.Status)
+	}
+
+	var st apiState
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return apiState{}, fmt.Errorf("GET /state: %w", err)
+	}
+	return st, nil
+}
+
+func printState(st apiState) {
+	fmt.Printf("State: %s", stateTitle(st.State))
+	if st.Summary != "" {
+		fmt.Printf(" (%s)", st.Summary)
+	}
+	fmt.Println()
+
+	switch o := st.Override; {
+	case o != nil && o.Paused:
+		fmt.Printf("Automation paused until %s\n", o.until())
+	case o != nil:
+		fmt.Printf("Marked %s until %s\n", stateTitle(o.State), o.until())
+	}
+	for _, d := range st.Detected {
+		fmt.Printf("  %s detected by %s: %s\n", d.State, d.Detector, d.Detail)
+	}
+
+	if len(st.Accounts) == 0 {
+		return
+	}
+	fmt.Println("Accounts:")
+	for _, a := range st.Accounts {
+		line := a.Name
+		if a.Team != "" {
+			line += " (" + a.Team + ")"
+		}
+		switch u := a.LastUpdate; {
+		case a.HealthError != "":
+			line += ": " + a.HealthError
+		case u == nil:
+			line += ": not updated yet"
+		case u.Error != "":
+			line += fmt.Sprintf(": update failed at %s: %s", u.At.Local().Format(time.Kitchen), u.Error)
+		default:
+			line += fmt.Sprintf(": set to %s at %s", u.State, u.At.Local().Format(time.Kitchen))
+		}
+		fmt.Println("  " + line)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+)
+
+func TestForceStateOverManualStatus(t *testing.T) {
+	fake := newTestWorkspace(t)
+	focus := slack.Profile{StatusText: "Focusing", StatusEmoji: ":headphones:"}
+	account := testAccount(fake, "work", focus)
+	account.RespectManualStatus = true
+	cfg := testConfig(account)
+	ctx := context.Background()
+
+	// The meeting status was replaced by hand.
+	written := meetingStatus
+	lunch := SlackStatus{StatusText: "Lunch", StatusEmoji: ":pizza:"}
+	store.Accounts[account.Name] = accountState{Snapshot: &lunch, Written: &written, State: presence.Meeting}
+
+	if err := forceState(ctx, cfg, presence.Signal{State: presence.Meeting}); err != nil {
+		t.Fatal(err)
+	}
+	if got := profileOf(t, fake, account); got.StatusText != meetingStatus.StatusText {
+		t.Errorf("after set, profile = %+v, want the meeting status", got)
+	}
+
+	fake.SetProfile(account.Token, focus)
+	if err := forceState(ctx, cfg, presence.Signal{State: presence.Available}); err != nil {
+		t.Fatal(err)
+	}
+	// Clear writes noMeetingStatus, not the saved lunch status.
+	if got := profileOf(t, fake, account); got != (slack.Profile{}) {
+		t.Errorf("after clear, profile = %+v, want noMeetingStatus", got)
+	}
+	if saved := store.account(account.Name); saved.Snapshot != nil || saved.State != presence.Available {
+		t.Errorf("saved = %+v, want available without a snapshot", saved)
+	}
+}
+
+func TestForceStateSkippedAccounts(t *testing.T) {
+	fake := newTestWorkspace(t)
+	work := testAccount(fake, "work", slack.Profile{})
+	off := testAccount(fake, "off", slack.Profile{})
+	enabled := false
+	off.Enabled = &enabled
+	cfg := testConfig(work, off)
+	ctx := context.Background()
+
+	if err := forceState(ctx, cfg, presence.Signal{State: presence.Meeting}); err != nil {
+		t.Fatalf("a disabled account failed the command: %v", err)
+	}
+	if got := profileOf(t, fake, off); got.StatusText != "" {
+		t.Errorf("disabled account profile = %+v, want it left alone", got)
+	}
+
+	broken := testAccount(fake, "broken", slack.Profile{})
+	health.set(broken.Name, accountHealth{Checked: true, Err: errors.New("invalid_auth")})
+	cfg = testConfig(work, broken)
+	if err := forceState(ctx, cfg, presence.Signal{State: presence.Available}); err != errUpdatesFailed {
+		t.Errorf("err = %v, want %v for the broken account", err, errUpdatesFailed)
+	}
+	if got := profileOf(t, fake, work); got.StatusText != "" {
+		t.Errorf("work profile = %+v, want it cleared despite the broken account", got)
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/detector"
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+type SlackStatus struct {
+	StatusText  string `mapstructure:"status_text" json:"status_text"`
+	StatusEmoji string `mapstructure:"status_emoji" json:"status_emoji"`
+	// StatusExpiration is the unix time at which Slack clears the status, or
+	// zero if it doesn't expire. It is set when a status is applied.
+	StatusExpiration int64 `mapstructure:"-" json:"status_expiration"`
+
+	// Presence, if set, is applied with users.setPresence: "away" or "auto".
+	Presence string `mapstructure:"presence" json:"-"`
+	// DNDMinutes, if set, snoozes notifications with dnd.setSnooze. For
+	// calendar meetings the snooze lasts until the end of the event instead.
+	DNDMinutes int `mapstructure:"dnd_minutes" json:"-"`
+}
+
+// expand fills in the {summary} and {end} placeholders with details of the
+// current meeting, as reported by the calendar detector, and shortens the
+// text to what Slack allows.
+func (s SlackStatus) expand(state detector.MeetingState) SlackStatus {
+	end := ""
+	if !state.End.IsZero() {
+		end = state.End.Format(time.Kitchen)
+	}
+	r := strings.NewReplacer("{summary}", state.Summary, "{end}", end)
+	s.StatusText = strings.TrimSpace(r.Replace(s.StatusText))
+	// A long event summary mustn't make Slack reject the status.
+	if text := []rune(s.StatusText); len(text) > maxStatusTextLength {
+		s.StatusText = string(text[:maxStatusTextLength-1]) + "…"
+	}
+	return s
+}
+
+// same reports whether two statuses show the same text and emoji.
+func (s SlackStatus) same(other SlackStatus) bool {
+	return s.StatusText == other.StatusText && s.StatusEmoji == other.StatusEmoji
+}
+
+// empty reports whether no status is set.
+func (s SlackStatus) empty() bool {
+	return s.StatusText == "" && s.StatusEmoji == ""
+}
+
+// expired reports whether Slack has cleared the status by now.
+func (s SlackStatus) expired(now time.Time) bool {
+	return s.StatusExpiration != 0 && s.StatusExpiration <= now.Unix()
+}
+
+type Account struct {
+	Name string `mapstructure:"name"`
+	// Token is the user token, or a reference to where it is kept, see
+	// resolveToken.
+	Token string `mapstructure:"token"`
+	// APIBaseURL is the Slack Web API root, for testing against a stand-in.
+	APIBaseURL string `mapstructure:"api_base_url"`
+	// Statuses maps state names to the status shown in that state.
+	Statuses map[string]*SlackStatus `mapstructure:"statuses"`
+
+	// MeetingStatus, NoMeetingStatus and CameraStatus are shorthands for the
+	// meeting, available and camera entries of Statuses.
+	MeetingStatus   *SlackStatus `mapstructure:"meetingStatus"`
+	NoMeetingStatus *SlackStatus `mapstructure:"noMeetingStatus"`
+	CameraStatus    *SlackStatus `mapstructure:"cameraStatus"`
+
+	// RespectManualStatus leaves a status that was changed by hand alone,
+	// until the next meeting starts.
+	RespectManualStatus bool `mapstructure:"respectManualStatus"`
+	// Enabled turns automation for the account off when false. It is set
+	// from the tray menu.
+	Enabled *bool `mapstructure:"enabled"`
+
+	client *slack.Client
+	// tokenErr is why the token reference couldn't be resolved.
+	tokenErr error
+}
+
+// enabled reports whether statuses are updated for the account.
+func (a Account) enabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+// statusFor returns the status to show in the given state. States without a
+// status of their own use the meeting status.
+func (a Account) statusFor(state string) SlackStatus {
+	if status, ok := a.Statuses[state]; ok && status != nil {
+		return *status
+	}
+	return *a.Statuses[presence.Meeting]
+}
+
+// DetectorConfig selects a registered meeting detector by name.
+type DetectorConfig struct {
+	Type string `mapstructure:"type"`
+	// State is the state the detector activates. By default it is camera
+	// when the camera is in use and meeting otherwise.
+	State   string                 `mapstructure:"state"`
+	Options map[string]interface{} `mapstructure:"options"`
+}
+
+// APIConfig enables the local control API.
+type APIConfig struct {
+	// Listen is a loopback address such as 127.0.0.1:8977, or unix:PATH for
+	// a Unix socket. The API is off if it is empty.
+	Listen string `mapstructure:"listen"`
+	// Token must be sent as a bearer token with every request. It may be a
+	// reference, see resolveToken.
+	Token string `mapstructure:"token"`
+}
+
+type Config struct {
+	Accounts []Account     `mapstructure:"accounts"`
+	Interval time.Duration `mapstructure:"interval"`
+	// StatusExpiry is how long a meeting status lasts in Slack if it isn't
+	// refreshed, so it can't get stuck if this program stops. Zero disables
+	// expiry.
+	StatusExpiry time.Duration `mapstructure:"statusExpiry"`
+	// UpdateTimeout bounds how long updating one account's status may take,
+	// retries included.
+	UpdateTimeout time.Duration    `mapstructure:"updateTimeout"`
+	Detectors     []DetectorConfig `mapstructure:"detectors"`
+	// States adds states, or changes the priority of the built-in ones.
+	States map[string]int `mapstructure:"states"`
+	API    APIConfig      `mapstructure:"api"`
+
+	priorities map[string]int
+	detectors  []stateDetector
+}
+
+// stateDetector is a configured detector and the state it activates.
+type stateDetector struct {
+	state    string
+	detector detector.MeetingDetector
+}
+
+var (
+	defaultMeetingStatus = SlackStatus{
+		StatusText:  "In a meeting",
+		StatusEmoji: ":zoom:",
+	}
+	defaultNoMeetingStatus               = SlackStatus{}
+	defaultInterval        time.Duration = 60 * time.Second
+	defaultStatusExpiry    time.Duration = 10 * time.Minute
+	defaultUpdateTimeout   time.Duration = 30 * time.Second
+	defaultDetectors                     = []DetectorConfig{{Type: detector.ZoomProcessName}}
+
+	config = newConfigStore()
+)
+
+// configStore holds the current configuration. A reload swaps in a new
+// Config atomically and wakes up the update loop, so the change applies right
+// away instead of after the current interval.
+type configStore struct {
+	current atomic.Value // *Config
+	changed chan struct{}
+
+	mu      sync.Mutex
+	loadErr error
+}
+
+func newConfigStore() *configStore {
+	s := &configStore{changed: make(chan struct{}, 1)}
+	s.current.Store(&Config{})
+	return s
+}
+
+// get returns the current configuration, which must not be modified.
+func (s *configStore) get() *Config {
+	return s.current.Load().(*Config)
+}
+
+// set replaces the configuration and signals the change.
+func (s *configStore) set(cfg *Config) {
+	s.mu.Lock()
+	s.current.Store(cfg)
+	s.loadErr = nil
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *configStore) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+		// A change is already pending.
+	}
+}
+
+// changes receives a value after the configuration has been replaced, or
+// could not be reloaded.
+func (s *configStore) changes() <-chan struct{} {
+	return s.changed
+}
+
+// setErr records why the config file could not be reloaded.
+func (s *configStore) setErr(err error) {
+	s.mu.Lock()
+	s.loadErr = err
+	s.mu.Unlock()
+	s.notify()
+}
+
+// err returns why the config file could not be reloaded, or nil if the
+// current config is the one in the file.
+func (s *configStore) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadErr
+}
+
+// Receiver functions for outputting Config and Account structures as strings.
+// Custom handling is necessary to output the contents of structs embedded via pointers.
+func (c Config) String() string {
+	return fmt.Sprintf("{Accounts:%v Interval:%v StatusExpiry:%v UpdateTimeout:%v Detectors:%v States:%v API:%v}", c.Accounts, c.Interval, c.StatusExpiry, c.UpdateTimeout, c.Detectors, c.priorities, c.API)
+}
+
+func (a APIConfig) String() string {
+	return fmt.Sprintf("{Listen:%v Token:%v}", a.Listen, redactToken(a.Token))
+}
+
+func (a Account) String() string {
+	states := make([]string, 0, len(a.Statuses))
+	for state := range a.Statuses {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	statuses := make([]string, 0, len(states))
+	for _, state := range states {
+		statuses = append(statuses, fmt.Sprintf("%s:%+v", state, *a.Statuses[state]))
+	}
+	return fmt.Sprintf("{Name:%v Token:%v APIBaseURL:%v Statuses:map[%s] RespectManualStatus:%v Enabled:%v}", a.Name, redactToken(a.Token), a.APIBaseURL, strings.Join(statuses, " "), a.RespectManualStatus, a.enabled())
+}
+
+// loadInConfig reads the config file and makes it the current config. If it
+// fails, the current config is left as it was.
+func loadInConfig() error {
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	// Create API clients.
+	for i := range cfg.Accounts {
+		account := &cfg.Accounts[i]
+		token, err := resolveToken(account.Token)
+		account.tokenErr = err
+		account.client = newSlackClient(token, account.APIBaseURL)
+	}
+
+	checkAccounts(cfg.Accounts)
+
+	config.set(cfg)
+
+	fmt.Printf("Configuration loaded:\n%v\n\n", cfg)
+	return nil
+}
+
+// readConfig reads and validates the config file, and sets up everything
+// that doesn't need the network or the accounts' tokens.
+func readConfig() (*Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, newConfigError(viper.ConfigFileUsed(), err)
+	}
+	file := viper.ConfigFileUsed()
+
+	cfg := &Config{}
+	if err := viper.Unmarshal(cfg); err != nil {
+		return nil, newConfigError(file, err)
+	}
+
+	cfg.priorities = presence.Priorities(cfg.States)
+	probs := cfg.validate()
+
+	if cfg.UpdateTimeout == 0 {
+		cfg.UpdateTimeout = defaultUpdateTimeout
+	}
+
+	// Set default status values if not configured.
+	for i := range cfg.Accounts {
+		account := &cfg.Accounts[i]
+		if account.Statuses == nil {
+			account.Statuses = map[string]*SlackStatus{}
+		}
+		setDefaultStatus(account.Statuses, presence.Meeting, account.MeetingStatus, &defaultMeetingStatus)
+		setDefaultStatus(account.Statuses, presence.Available, account.NoMeetingStatus, &defaultNoMeetingStatus)
+		setDefaultStatus(account.Statuses, presence.Camera, account.CameraStatus, account.Statuses[presence.Meeting])
+	}
+
+	if len(cfg.Detectors) == 0 {
+		cfg.Detectors = defaultDetectors
+	}
+	for i, d := range cfg.Detectors {
+		md, err := detector.New(d.Type, d.Options)
+		if err != nil {
+			probs = append(probs, problem{fmt.Sprintf("detectors[%d]", i), err.Error()})
+			continue
+		}
+		cfg.detectors = append(cfg.detectors, stateDetector{state: d.State, detector: md})
+	}
+
+	if len(probs) > 0 {
+		return nil, newConfigError(file, &validationError{Problems: probs})
+	}
+	return cfg, nil
+}
+
+// setAccountEnabled turns automation for an account on or off in the config
+// file. The change is applied when the file is reloaded.
+func setAccountEnabled(name string, enabled bool) error {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return errors.New("no config file in use")
+	}
+
+	// A separate viper instance, so the setting doesn't override the file
+	// in the one the app reads from, and isn't used from two goroutines.
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return newConfigError(path, err)
+	}
+
+	accounts, _ := v.Get("accounts").([]interface{})
+	found := false
+	for _, a := range accounts {
+		if m, ok := a.(map[interface{}]interface{}); ok && m["name"] == name {
+			m["enabled"] = enabled
+			found = true
+		}
+		if m, ok := a.(map[string]interface{}); ok && m["name"] == name {
+			m["enabled"] = enabled
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("account %q is not in %s", name, path)
+	}
+	v.Set("accounts", accounts)
+	return v.WriteConfig()
+}
+
+// reloadConfig loads the config file again after it changed. If it can't be
+// loaded, for example because it is only half saved, the last good config
+// stays in use and the error is shown until the file changes again.
+func reloadConfig() {
+	if err := loadInConfig(); err != nil {
+		fmt.Printf("Could not reload config, keeping the last good one: %s\n", err)
+		config.setErr(err)
+	}
+}
+
+// configError is a problem with the config file.
+type configError struct {
+	File string
+	// Line is where in the file the problem is, zero if unknown.
+	Line int
+	Err  error
+}
+
+func (e *configError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+func (e *configError) Unwrap() error {
+	return e.Err
+}
+
+// parseErrorLine finds the line number in errors from the YAML and TOML
+// parsers, e.g. "yaml: line 3: did not find expected key".
+var parseErrorLine = regexp.MustCompile(`line (\d+)(?:, column \d+)?: `)
+
+// newConfigError adds the file, and the line if the parser reported one, to
+// an error from loading the config file.
+func newConfigError(file string, err error) error {
+	if file == "" {
+		return err
+	}
+	var decodeErr *mapstructure.Error
+	if errors.As(err, &decodeErr) {
+		// Put each problem on one line, instead of a bulleted list.
+		return &configError{File: file, Err: errors.New(strings.Join(decodeErr.Errors, "; "))}
+	}
+	var parseErr viper.ConfigParseError
+	if !errors.As(err, &parseErr) {
+		return &configError{File: file, Err: err}
+	}
+
+	// ConfigParseError doesn't give access to the parser's error.
+	msg := strings.TrimPrefix(err.Error(), "While parsing config: ")
+	e := &configError{File: file}
+	if m := parseErrorLine.FindStringSubmatchIndex(msg); m != nil {
+		e.Line, _ = strconv.Atoi(msg[m[2]:m[3]])
+		msg = msg[:m[0]] + msg[m[1]:]
+	}
+	e.Err = errors.New(msg)
+	return e
+}
+
+// setDefaultStatus fills in a state's status from its shorthand field, or
+// the default if neither is configured.
+func setDefaultStatus(statuses map[string]*SlackStatus, state string, shorthand, def *SlackStatus) {
+	if statuses[state] != nil {
+		return
+	}
+	if shorthand != nil {
+		statuses[state] = shorthand
+	} else {
+		statuses[state] = def
+	}
+}
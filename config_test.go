@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/detector"
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+)
+
+// testSwitch is the detector configured as type test-switch.
+var testSwitch = &switchDetector{}
+
+func init() {
+	detector.Register("test-switch", func(options map[string]interface{}) (detector.MeetingDetector, error) {
+		return testSwitch, nil
+	})
+}
+
+// testConfigFile is a config file for a single account on the fake server.
+func testConfigFile(apiURL, meetingText string) string {
+	return fmt.Sprintf(`interval: 1h
+accounts:
+- name: work
+  token: xoxp-work
+  api_base_url: %s
+  meetingStatus:
+    status_text: %s
+    status_emoji: ":zoom:"
+detectors:
+- type: test-switch
+`, apiURL, meetingText)
+}
+
+func TestReloadWakesLoop(t *testing.T) {
+	fake := newTestWorkspace(t)
+	account := testAccount(fake, "work", slack.Profile{})
+	testSwitch.set(false)
+
+	path := useConfigFile(t, testConfigFile(fake.APIURL(), "In a meeting"))
+	if err := loadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	shown := startLoop(t)
+	if tr := nextState(t, shown, 5*time.Second); tr.To.State != presence.Available {
+		t.Fatalf("transition = %+v, want available", tr)
+	}
+
+	// Readers elsewhere, like the API and the tray, use the config while it
+	// is being replaced.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				cfg := config.get()
+				_ = len(cfg.Accounts) + int(cfg.Interval)
+				config.err()
+			}
+		}()
+	}
+
+	// Reloads come from the file watcher's goroutine while the loop runs.
+	testSwitch.set(true)
+	for i := 0; i < 5; i++ {
+		text := fmt.Sprintf("Busy %d", i)
+		if err := ioutil.WriteFile(path, []byte(testConfigFile(fake.APIURL(), text)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		reloadConfig()
+	}
+
+	// The interval is an hour, only the reload can have woken the loop.
+	deadline := time.After(5 * time.Second)
+	for {
+		var tr presence.Transition
+		select {
+		case tr = <-shown:
+		case <-deadline:
+			t.Fatal("the loop didn't wake up after the reload")
+		}
+		if tr.To.State != presence.Meeting {
+			continue
+		}
+		if got := profileOf(t, fake, account); got.StatusText == "Busy 4" {
+			break
+		}
+	}
+
+	// A broken file keeps the last good config and still wakes the loop to
+	// show the error.
+	if err := ioutil.WriteFile(path, []byte("accounts: ["), 0600); err != nil {
+		t.Fatal(err)
+	}
+	last := config.get()
+	reloadConfig()
+	nextState(t, shown, 5*time.Second)
+	if config.get() != last || config.err() == nil || !strings.Contains(config.err().Error(), path) {
+		t.Errorf("config err = %v, want the parse error with the last good config kept", config.err())
+	}
+
+	close(stop)
+	wg.Wait()
+}
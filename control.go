@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+)
+
+// override forces a state regardless of what the detectors report, or
+// pauses automation so statuses are left alone, until a given time.
+type override struct {
+	State  string    `json:"state,omitempty"`
+	Paused bool      `json:"paused,omitempty"`
+	Until  time.Time `json:"until"`
+}
+
+// until formats the end of the override, with the day if it isn't today.
+func (o override) until() string {
+	until := o.Until.Local()
+	if y, m, d := until.Date(); time.Now().Before(time.Date(y, m, d, 0, 0, 0, 0, time.Local)) {
+		return until.Format("Mon " + time.Kitchen)
+	}
+	return until.Format(time.Kitchen)
+}
+
+// controller is how the update loop is steered from outside it, and how the
+// outcome of its last check is shared.
+type controller struct {
+	mu       sync.Mutex
+	override *override
+	// detected are the states the detectors reported at the last check.
+	detected  []presence.Signal
+	current   presence.Signal
+	checkedAt time.Time
+
+	wake chan struct{}
+}
+
+var control = newController()
+
+func newController() *controller {
+	return &controller{
+		current: presence.Signal{State: presence.Available},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// activeOverride returns the override in effect at now, if any.
+func (c *controller) activeOverride(now time.Time) (override, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.override == nil || !now.Before(c.override.Until) {
+		return override{}, false
+	}
+	return *c.override, true
+}
+
+// restore puts back the override saved by an earlier run, unless it has
+// expired since.
+func (c *controller) restore(o *override) {
+	if o == nil || !time.Now().Before(o.Until) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.override = o
+}
+
+// setOverride sets the override, saves it, and wakes the loop to apply it.
+func (c *controller) setOverride(o override) {
+	c.mu.Lock()
+	c.override = &o
+	c.mu.Unlock()
+
+	if err := store.setOverride(&o); err != nil {
+		fmt.Printf("Could not save override: %s\n", err)
+	}
+	c.refresh()
+}
+
+// clearOverride goes back to the detected state. It reports whether there was
+// an override to clear.
+func (c *controller) clearOverride() bool {
+	c.mu.Lock()
+	had := c.override != nil && time.Now().Before(c.override.Until)
+	c.override = nil
+	c.mu.Unlock()
+
+	if err := store.setOverride(nil); err != nil {
+		fmt.Printf("Could not save override: %s\n", err)
+	}
+	if had {
+		c.refresh()
+	}
+	return had
+}
+
+// refresh wakes the loop to check for meetings right away.
+func (c *controller) refresh() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+		// A check is already pending.
+	}
+}
+
+// wakeups receives a value when the loop should check right away.
+func (c *controller) wakeups() <-chan struct{} {
+	return c.wake
+}
+
+// checked records the outcome of a check.
+func (c *controller) checked(detected []presence.Signal, current presence.Signal, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.detected, c.current, c.checkedAt = detected, current, at
+}
+
+// last returns the outcome of the last check.
+func (c *controller) last() (detected []presence.Signal, current presence.Signal, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.detected, c.current, c.checkedAt
+}
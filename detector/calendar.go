@@ -0,0 +1,185 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/ical"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// CalendarName is the detector name for Calendar.
+const CalendarName = "calendar"
+
+func init() {
+	Register(CalendarName, func(options map[string]interface{}) (MeetingDetector, error) {
+		d := &Calendar{Refresh: 15 * time.Minute}
+		if err := decodeOptions(options, d); err != nil {
+			return nil, err
+		}
+		if (d.File == "") == (d.URL == "") {
+			return nil, fmt.Errorf("exactly one of file or url must be set")
+		}
+		file, err := homedir.Expand(d.File)
+		if err != nil {
+			return nil, err
+		}
+		d.File = file
+		if d.Summary != "" {
+			re, err := regexp.Compile(d.Summary)
+			if err != nil {
+				return nil, fmt.Errorf("invalid summary pattern: %w", err)
+			}
+			d.summary = re
+		}
+		return d, nil
+	})
+}
+
+// Calendar reports a meeting during busy events of an iCalendar file or
+// feed. Events marked as free (TRANSP:TRANSPARENT) or cancelled are ignored.
+type Calendar struct {
+	// File is the path of a local .ics file. It is re-read when it changes.
+	File string `mapstructure:"file"`
+	// URL is an iCalendar feed, e.g. a calendar's secret iCal address.
+	URL string `mapstructure:"url"`
+	// Refresh is how often the feed at URL is downloaded.
+	Refresh time.Duration `mapstructure:"refresh"`
+	// IncludeAllDay counts busy all-day events as meetings.
+	IncludeAllDay bool `mapstructure:"includeAllDay"`
+	// Summary, if set, is a regular expression an event summary must match.
+	Summary string `mapstructure:"summary"`
+
+	summary *regexp.Regexp
+
+	mu       sync.Mutex
+	cal      *ical.Calendar
+	loadedAt time.Time
+	modTime  time.Time
+}
+
+func (c *Calendar) Detect(ctx context.Context) (MeetingState, error) {
+	cal, loadErr := c.calendar(ctx)
+	if cal == nil {
+		return MeetingState{}, loadErr
+	}
+
+	var current *ical.Occurrence
+	for _, o := range cal.Busy(time.Now()) {
+		o := o
+		if o.Event.AllDay && !c.IncludeAllDay {
+			continue
+		}
+		if c.summary != nil && !c.summary.MatchString(o.Event.Summary) {
+			continue
+		}
+		// With overlapping events, report the one that keeps the user busy
+		// the longest.
+		if current == nil || o.End.After(current.End) {
+			current = &o
+		}
+	}
+
+	detail := ""
+	if len(cal.Unsupported) > 0 {
+		detail = fmt.Sprintf(" (%d events skipped: %s)", len(cal.Unsupported), strings.Join(cal.Unsupported, "; "))
+	}
+	if loadErr != nil {
+		detail += fmt.Sprintf(" (using cached calendar: %s)", loadErr)
+	}
+	if current == nil {
+		return MeetingState{Detector: CalendarName, Detail: "no busy events" + detail}, nil
+	}
+	return MeetingState{
+		InMeeting: true,
+		Detector:  CalendarName,
+		Summary:   current.Event.Summary,
+		End:       current.End,
+		Detail:    fmt.Sprintf("%q until %s%s", current.Event.Summary, current.End.Format(time.Kitchen), detail),
+	}, nil
+}
+
+// calendar returns the parsed calendar, reloading it when the file changed
+// or the feed is due for a refresh. If a reload fails, the previously loaded
+// calendar is returned along with the error.
+func (c *Calendar) calendar(ctx context.Context) (*ical.Calendar, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		cal *ical.Calendar
+		err error
+	)
+	if c.File != "" {
+		cal, err = c.loadFile()
+	} else {
+		cal, err = c.loadURL(ctx)
+	}
+	if cal != nil {
+		c.cal = cal
+	}
+	return c.cal, err
+}
+
+func (c *Calendar) loadFile() (*ical.Calendar, error) {
+	info, err := os.Stat(c.File)
+	if err != nil {
+		return nil, err
+	}
+	if c.cal != nil && info.ModTime().Equal(c.modTime) {
+		return nil, nil
+	}
+
+	f, err := os.Open(c.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cal, err := ical.Parse(f, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.File, err)
+	}
+	c.modTime = info.ModTime()
+	return cal, nil
+}
+
+func (c *Calendar) loadURL(ctx context.Context) (*ical.Calendar, error) {
+	if c.cal != nil && time.Since(c.loadedAt) < c.Refresh {
+		return nil, nil
+	}
+	// Calendar apps hand out webcal:// links for the same https feed.
+	url := c.URL
+	if strings.HasPrefix(url, "webcal://") {
+		url = "https://" + strings.TrimPrefix(url, "webcal://")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching calendar: %s", resp.Status)
+	}
+	cal, err := ical.Parse(resp.Body, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("calendar feed: %w", err)
+	}
+	c.loadedAt = time.Now()
+	return cal, nil
+}
@@ -0,0 +1,222 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// icsEvent returns a calendar with a single event from start to end.
+func icsEvent(summary string, start, end time.Time) string {
+	const layout = "20060102T150405Z"
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:" + summary + "@example.com",
+		"SUMMARY:" + summary,
+		"DTSTART:" + start.UTC().Format(layout),
+		"DTEND:" + end.UTC().Format(layout),
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+}
+
+// feed serves a calendar that tests can replace or make fail.
+type feed struct {
+	mu       sync.Mutex
+	body     string
+	status   int
+	requests int
+}
+
+func (f *feed) set(status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status, f.body = status, body
+}
+
+func (f *feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	w.WriteHeader(f.status)
+	fmt.Fprint(w, f.body)
+}
+
+func (f *feed) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func detectCalendar(t *testing.T, d MeetingDetector) MeetingState {
+	t.Helper()
+	state, err := d.Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestCalendarURL(t *testing.T) {
+	now := time.Now()
+	f := &feed{}
+	f.set(http.StatusOK, icsEvent("Planning", now.Add(-10*time.Minute), now.Add(50*time.Minute)))
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	d, err := New(CalendarName, map[string]interface{}{"url": server.URL, "refresh": "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := detectCalendar(t, d)
+	if !state.InMeeting || state.Summary != "Planning" || state.End.Unix() != now.Add(50*time.Minute).Unix() {
+		t.Errorf("Detect() = %+v, want Planning in progress", state)
+	}
+
+	// Within the refresh interval the feed isn't downloaded again.
+	f.set(http.StatusOK, icsEvent("Later", now.Add(time.Hour), now.Add(2*time.Hour)))
+	if state := detectCalendar(t, d); !state.InMeeting || f.count() != 1 {
+		t.Errorf("Detect() = %+v after %d requests, want the cached calendar after 1", state, f.count())
+	}
+}
+
+func TestCalendarURLRefresh(t *testing.T) {
+	now := time.Now()
+	f := &feed{}
+	f.set(http.StatusOK, icsEvent("Planning", now.Add(-10*time.Minute), now.Add(50*time.Minute)))
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	d, err := New(CalendarName, map[string]interface{}{"url": server.URL, "refresh": "1ns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := detectCalendar(t, d); !state.InMeeting {
+		t.Fatalf("Detect() = %+v, want in a meeting", state)
+	}
+
+	f.set(http.StatusOK, icsEvent("Later", now.Add(time.Hour), now.Add(2*time.Hour)))
+	state := detectCalendar(t, d)
+	if state.InMeeting || state.Detail != "no busy events" {
+		t.Errorf("Detect() = %+v, want the refreshed calendar", state)
+	}
+
+	// A failed refresh keeps using the last calendar and says so.
+	f.set(http.StatusInternalServerError, "oops")
+	state = detectCalendar(t, d)
+	if state.InMeeting || !strings.Contains(state.Detail, "using cached calendar: fetching calendar: 500") {
+		t.Errorf("Detect() = %+v, want the cached calendar", state)
+	}
+	f.set(http.StatusOK, "not a calendar")
+	state = detectCalendar(t, d)
+	if !strings.Contains(state.Detail, "using cached calendar: calendar feed:") {
+		t.Errorf("Detect() = %+v, want the cached calendar", state)
+	}
+
+	f.set(http.StatusOK, icsEvent("Planning", now.Add(-10*time.Minute), now.Add(50*time.Minute)))
+	if state := detectCalendar(t, d); !state.InMeeting || strings.Contains(state.Detail, "cached") {
+		t.Errorf("Detect() = %+v, want the recovered feed", state)
+	}
+	if f.count() != 5 {
+		t.Errorf("feed requested %d times, want 5", f.count())
+	}
+}
+
+func TestCalendarURLFirstLoadFails(t *testing.T) {
+	f := &feed{}
+	f.set(http.StatusNotFound, "")
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	d, err := New(CalendarName, map[string]interface{}{"url": server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Detect(context.Background()); err == nil {
+		t.Error("expected an error without a calendar to fall back to")
+	}
+}
+
+func TestCalendarFile(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	write := func(body string, modTime time.Time) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(icsEvent("Interview", now.Add(-time.Minute), now.Add(time.Hour)), now.Add(-time.Hour))
+
+	d, err := New(CalendarName, map[string]interface{}{"file": path, "summary": "^Interview$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := detectCalendar(t, d); !state.InMeeting || state.Summary != "Interview" {
+		t.Fatalf("Detect() = %+v, want Interview", state)
+	}
+
+	// The file is re-read when it changes.
+	write(icsEvent("Internal sync", now.Add(-time.Minute), now.Add(time.Hour)), now)
+	if state := detectCalendar(t, d); state.InMeeting {
+		t.Errorf("Detect() = %+v, want the summary filter to skip Internal sync", state)
+	}
+
+	write("BEGIN:VCALENDAR\r\n", now.Add(time.Minute))
+	state := detectCalendar(t, d)
+	if state.InMeeting || !strings.Contains(state.Detail, "using cached calendar") {
+		t.Errorf("Detect() = %+v, want the cached calendar", state)
+	}
+}
+
+func TestCalendarAllDay(t *testing.T) {
+	today := time.Now().Format("20060102")
+	body := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:holiday@example.com",
+		"SUMMARY:Holiday",
+		"DTSTART;VALUE=DATE:" + today,
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	if err := ioutil.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, includeAllDay := range []bool{false, true} {
+		d, err := New(CalendarName, map[string]interface{}{"file": path, "includeAllDay": includeAllDay})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state := detectCalendar(t, d); state.InMeeting != includeAllDay {
+			t.Errorf("includeAllDay %v: InMeeting = %v", includeAllDay, state.InMeeting)
+		}
+	}
+}
+
+func TestCalendarOptions(t *testing.T) {
+	for _, options := range []map[string]interface{}{
+		{},
+		{"file": "a.ics", "url": "https://example.com/a.ics"},
+		{"file": "a.ics", "summary": "("},
+	} {
+		if _, err := New(CalendarName, options); err == nil {
+			t.Errorf("New(%v): expected an error", options)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package detector provides pluggable checks for whether the user is
+// currently in a meeting.
+package detector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// MeetingState is the result of a single detection pass.
+type MeetingState struct {
+	InMeeting bool
+	// OnCamera is set when the user's camera is known to be in use.
+	OnCamera bool
+	// Detector is the name of the detector that produced this state.
+	Detector string
+	// Process is the executable responsible for the meeting, if known.
+	Process string
+	// Summary and End describe the meeting, if known from a calendar.
+	Summary string
+	End     time.Time
+	// Detail is a short human readable explanation of the result.
+	Detail string
+}
+
+// MeetingDetector reports whether a meeting is currently in progress.
+type MeetingDetector interface {
+	Detect(ctx context.Context) (MeetingState, error)
+}
+
+// Factory creates a detector from the options map given in the config file.
+type Factory func(options map[string]interface{}) (MeetingDetector, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a detector available under the given name. It panics if
+// the name is already taken.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic("detector: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Names returns the sorted names of all registered detectors.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the detector registered under name.
+func New(name string, options map[string]interface{}) (MeetingDetector, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown detector %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	d, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("detector %q: %w", name, err)
+	}
+	return d, nil
+}
+
+// decodeOptions copies the config file options into a detector specific
+// options struct.
+func decodeOptions(options map[string]interface{}, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+		// A list given in the config replaces the default rather than
+		// overwriting its first elements.
+		ZeroFields: true,
+		Result:     out,
+	})
+	if err != nil {
+		return err
+	}
+	// mapstructure leaves the default in place for an empty list, but
+	// clears the field for nil.
+	cleared := make(map[string]interface{}, len(options))
+	for key, value := range options {
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Slice && v.Len() == 0 {
+			value = nil
+		}
+		cleared[key] = value
+	}
+	return decoder.Decode(cleared)
+}
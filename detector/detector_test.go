@@ -0,0 +1,39 @@
+package detector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeOptions(t *testing.T) {
+	type options struct {
+		List     []string      `mapstructure:"list"`
+		Interval time.Duration `mapstructure:"interval"`
+	}
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		want    options
+		wantErr bool
+	}{
+		{name: "defaults kept", want: options{List: []string{"a", "b", "c"}, Interval: time.Minute}},
+		{name: "shorter list replaces default", options: map[string]interface{}{"list": []interface{}{"x"}}, want: options{List: []string{"x"}, Interval: time.Minute}},
+		{name: "empty list clears default", options: map[string]interface{}{"list": []interface{}{}}, want: options{Interval: time.Minute}},
+		{name: "single value", options: map[string]interface{}{"list": "x"}, want: options{List: []string{"x"}, Interval: time.Minute}},
+		{name: "duration string", options: map[string]interface{}{"interval": "90s"}, want: options{List: []string{"a", "b", "c"}, Interval: 90 * time.Second}},
+		{name: "unknown option", options: map[string]interface{}{"lsit": []interface{}{"x"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := options{List: []string{"a", "b", "c"}, Interval: time.Minute}
+			err := decodeOptions(tt.options, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
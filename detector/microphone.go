@@ -0,0 +1,150 @@
+package detector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MicrophoneName is the detector name for Microphone.
+const MicrophoneName = "microphone"
+
+func init() {
+	Register(MicrophoneName, func(options map[string]interface{}) (MeetingDetector, error) {
+		d := &Microphone{
+			Command: []string{"pactl", "list", "source-outputs"},
+			Deny:    []string{"pavucontrol"},
+		}
+		if err := decodeOptions(options, d); err != nil {
+			return nil, err
+		}
+		if len(d.Command) == 0 {
+			return nil, fmt.Errorf("command must not be empty")
+		}
+		return d, nil
+	})
+}
+
+// Microphone detects a call by looking for applications with an active
+// capture stream on a PulseAudio or PipeWire (through pipewire-pulse) source.
+type Microphone struct {
+	// Command lists the source outputs in the `pactl list source-outputs`
+	// format.
+	Command []string `mapstructure:"command"`
+	// Allow, if set, limits detection to these applications.
+	Allow []string `mapstructure:"allow"`
+	// Deny ignores these applications, e.g. dictation tools or level meters.
+	Deny []string `mapstructure:"deny"`
+
+	// run executes Command, it is replaced in tests.
+	run func(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// sourceOutput is a single capture stream reported by pactl.
+type sourceOutput struct {
+	Index      int
+	Corked     bool
+	Properties map[string]string
+}
+
+// Application returns the names the stream's application is known by.
+func (s sourceOutput) Application() []string {
+	var names []string
+	for _, key := range []string{"application.name", "application.process.binary", "application.id"} {
+		if v := s.Properties[key]; v != "" {
+			names = append(names, v)
+		}
+	}
+	return names
+}
+
+func (m *Microphone) Detect(ctx context.Context) (MeetingState, error) {
+	run := m.run
+	if run == nil {
+		run = runCommand
+	}
+	out, err := run(ctx, m.Command[0], m.Command[1:]...)
+	if err != nil {
+		return MeetingState{}, fmt.Errorf("could not list source outputs: %w", err)
+	}
+
+	for _, output := range parseSourceOutputs(out) {
+		if output.Corked || !m.allowed(output.Application()) {
+			continue
+		}
+		app := "source output #" + strconv.Itoa(output.Index)
+		if names := output.Application(); len(names) > 0 {
+			app = names[0]
+		}
+		return MeetingState{
+			InMeeting: true,
+			Detector:  MicrophoneName,
+			Detail:    "microphone in use by " + app,
+		}, nil
+	}
+	return MeetingState{Detector: MicrophoneName, Detail: "microphone not in use"}, nil
+}
+
+// allowed applies the allow and deny lists to an application's names.
+func (m *Microphone) allowed(names []string) bool {
+	for _, name := range names {
+		if containsFold(m.Deny, name) {
+			return false
+		}
+	}
+	if len(m.Allow) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if containsFold(m.Allow, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSourceOutputs parses the output of `pactl list source-outputs`.
+func parseSourceOutputs(out []byte) []sourceOutput {
+	var (
+		outputs []sourceOutput
+		current *sourceOutput
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Source Output #") {
+			index, _ := strconv.Atoi(strings.TrimPrefix(line, "Source Output #"))
+			outputs = append(outputs, sourceOutput{Index: index, Properties: map[string]string{}})
+			current = &outputs[len(outputs)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if strings.HasPrefix(line, "Corked:") {
+			current.Corked = strings.TrimSpace(strings.TrimPrefix(line, "Corked:")) == "yes"
+			continue
+		}
+		// Properties are indented `key = "value"` lines.
+		if i := strings.Index(line, " = "); i > 0 {
+			value := strings.TrimSpace(line[i+3:])
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			current.Properties[line[:i]] = value
+		}
+	}
+	return outputs
+}
+
+// runCommand runs a command with a fixed locale so its output can be parsed.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	return cmd.Output()
+}
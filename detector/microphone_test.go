@@ -0,0 +1,144 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseSourceOutputs(t *testing.T) {
+	outputs := parseSourceOutputs(readTestdata(t, "pactl-source-outputs.txt"))
+	if len(outputs) != 3 {
+		t.Fatalf("got %d source outputs, want 3", len(outputs))
+	}
+
+	var indexes []int
+	var corked []bool
+	for _, o := range outputs {
+		indexes = append(indexes, o.Index)
+		corked = append(corked, o.Corked)
+	}
+	if want := []int{42, 57, 63}; !reflect.DeepEqual(indexes, want) {
+		t.Errorf("indexes = %v, want %v", indexes, want)
+	}
+	if want := []bool{true, false, false}; !reflect.DeepEqual(corked, want) {
+		t.Errorf("corked = %v, want %v", corked, want)
+	}
+
+	zoom := outputs[2]
+	if got := zoom.Properties["application.process.binary"]; got != "zoom" {
+		t.Errorf("application.process.binary = %q, want zoom", got)
+	}
+	if got := zoom.Properties["media.name"]; got != "RecordStream" {
+		t.Errorf("media.name = %q, want RecordStream", got)
+	}
+	if want := []string{"ZOOM VoiceEngine", "zoom"}; !reflect.DeepEqual(zoom.Application(), want) {
+		t.Errorf("Application() = %q, want %q", zoom.Application(), want)
+	}
+	if want := []string{"PulseAudio Volume Control", "pavucontrol", "org.PulseAudio.pavucontrol"}; !reflect.DeepEqual(outputs[1].Application(), want) {
+		t.Errorf("Application() = %q, want %q", outputs[1].Application(), want)
+	}
+}
+
+func TestParseSourceOutputsEmpty(t *testing.T) {
+	if outputs := parseSourceOutputs(nil); len(outputs) != 0 {
+		t.Errorf("got %d source outputs, want none", len(outputs))
+	}
+	if outputs := parseSourceOutputs([]byte("Failed to connect\n\tCorked: no\n")); len(outputs) != 0 {
+		t.Errorf("got %d source outputs, want none", len(outputs))
+	}
+}
+
+func TestMicrophoneDetect(t *testing.T) {
+	captured := readTestdata(t, "pactl-source-outputs.txt")
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		want    bool
+		detail  string
+	}{
+		{
+			name:   "pavucontrol denied by default",
+			want:   true,
+			detail: "microphone in use by ZOOM VoiceEngine",
+		},
+		{
+			name:    "allowed by binary",
+			options: map[string]interface{}{"allow": []string{"ZOOM"}},
+			want:    true,
+			detail:  "microphone in use by ZOOM VoiceEngine",
+		},
+		{
+			name:    "not allowed",
+			options: map[string]interface{}{"allow": []string{"firefox"}},
+			detail:  "microphone not in use",
+		},
+		{
+			name:    "denied by name",
+			options: map[string]interface{}{"deny": []string{"pavucontrol", "zoom voiceengine"}},
+			detail:  "microphone not in use",
+		},
+		{
+			name:    "deny wins over allow",
+			options: map[string]interface{}{"allow": []string{"zoom"}, "deny": []string{"zoom"}},
+			detail:  "microphone not in use",
+		},
+		{
+			name:    "empty deny list",
+			options: map[string]interface{}{"deny": []string{}},
+			want:    true,
+			detail:  "microphone in use by PulseAudio Volume Control",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(MicrophoneName, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := d.(*Microphone)
+			m.run = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				if got := append([]string{name}, args...); !reflect.DeepEqual(got, []string{"pactl", "list", "source-outputs"}) {
+					t.Errorf("ran %q", got)
+				}
+				return captured, nil
+			}
+			got, err := m.Detect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.InMeeting != tt.want || got.Detail != tt.detail {
+				t.Errorf("Detect() = %v %q, want %v %q", got.InMeeting, got.Detail, tt.want, tt.detail)
+			}
+		})
+	}
+}
+
+func TestMicrophoneCommandError(t *testing.T) {
+	m := &Microphone{
+		Command: []string{"pactl"},
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+	if _, err := m.Detect(context.Background()); err == nil {
+		t.Error("expected an error when the command fails")
+	}
+}
+
+func TestMicrophoneEmptyCommand(t *testing.T) {
+	if _, err := New(MicrophoneName, map[string]interface{}{"command": []string{}}); err == nil {
+		t.Error("expected an error for an empty command")
+	}
+}
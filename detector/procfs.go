@@ -0,0 +1,144 @@
+package detector
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultProcRoot is where the proc filesystem is mounted on Linux.
+const defaultProcRoot = "/proc"
+
+// process is the subset of /proc/<pid> that detectors need.
+type process struct {
+	PID     int
+	PPID    int
+	Comm    string
+	Cmdline []string
+}
+
+// Executable returns the base name of the process executable. The command
+// line is preferred because comm is truncated to 15 characters by the kernel.
+func (p process) Executable() string {
+	if len(p.Cmdline) > 0 && p.Cmdline[0] != "" {
+		return filepath.Base(p.Cmdline[0])
+	}
+	return p.Comm
+}
+
+// listPIDs returns the numeric directories under root.
+func listPIDs(root string) ([]int, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// readProcesses reads every process under root. Processes that exit while
+// being read are skipped.
+func readProcesses(root string) ([]process, error) {
+	pids, err := listPIDs(root)
+	if err != nil {
+		return nil, err
+	}
+	processes := make([]process, 0, len(pids))
+	for _, pid := range pids {
+		p, err := readProcess(root, pid)
+		if err != nil {
+			continue
+		}
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+func readProcess(root string, pid int) (process, error) {
+	dir := filepath.Join(root, strconv.Itoa(pid))
+
+	stat, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return process{}, err
+	}
+	p, err := parseStat(stat)
+	if err != nil {
+		return process{}, fmt.Errorf("%s: %w", dir, err)
+	}
+
+	cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return process{}, err
+	}
+	p.Cmdline = parseCmdline(cmdline)
+	return p, nil
+}
+
+// parseStat extracts the pid, comm and parent pid from /proc/<pid>/stat. The
+// comm field is wrapped in parentheses and may itself contain spaces or
+// parentheses, so the fields after it are located from the last ')'.
+func parseStat(stat []byte) (process, error) {
+	s := string(stat)
+	open := strings.IndexByte(s, '(')
+	end := strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return process{}, fmt.Errorf("malformed stat %q", s)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(s[:open]))
+	if err != nil {
+		return process{}, fmt.Errorf("malformed stat pid: %w", err)
+	}
+	// Fields after comm: state ppid ...
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 2 {
+		return process{}, fmt.Errorf("malformed stat %q", s)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return process{}, fmt.Errorf("malformed stat ppid: %w", err)
+	}
+	return process{PID: pid, PPID: ppid, Comm: s[open+1 : end]}, nil
+}
+
+// parseCmdline splits the NUL separated /proc/<pid>/cmdline contents.
+func parseCmdline(cmdline []byte) []string {
+	cmdline = bytes.TrimRight(cmdline, "\x00")
+	if len(cmdline) == 0 {
+		return nil
+	}
+	return strings.Split(string(cmdline), "\x00")
+}
+
+// descendants returns every process below the given pid.
+func descendants(processes []process, pid int) []process {
+	children := map[int][]process{}
+	for _, p := range processes {
+		children[p.PPID] = append(children[p.PPID], p)
+	}
+	var out []process
+	queue := []int{pid}
+	seen := map[int]bool{pid: true}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, child := range children[next] {
+			if seen[child.PID] {
+				continue
+			}
+			seen[child.PID] = true
+			out = append(out, child)
+			queue = append(queue, child.PID)
+		}
+	}
+	return out
+}
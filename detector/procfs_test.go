@@ -0,0 +1,133 @@
+package detector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeProc creates /proc/<pid>/stat and cmdline under root.
+func writeProc(t *testing.T, root string, pid, ppid int, comm string, args ...string) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stat := fmt.Sprintf("%d (%s) S %d 1 1 0 -1 4194560 0 0 0 0\n", pid, comm, ppid)
+	if err := ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmdline := ""
+	if len(args) > 0 {
+		cmdline = strings.Join(args, "\x00") + "\x00"
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		stat    string
+		want    process
+		wantErr bool
+	}{
+		{stat: "42 (zoom) S 1 42 42 0", want: process{PID: 42, PPID: 1, Comm: "zoom"}},
+		{stat: "7 (Web Content) R 3 7", want: process{PID: 7, PPID: 3, Comm: "Web Content"}},
+		{stat: "9 (a) b (c)) S 8 9", want: process{PID: 9, PPID: 8, Comm: "a) b (c)"}},
+		{stat: "5 () S 2 5", want: process{PID: 5, PPID: 2, Comm: ""}},
+		{stat: "5 (zoom S 2 5", wantErr: true},
+		{stat: "x (zoom) S 2 5", wantErr: true},
+		{stat: "5 (zoom) S", wantErr: true},
+		{stat: "5 (zoom) S x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseStat([]byte(tt.stat))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStat(%q) error = %v, want error %v", tt.stat, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStat(%q) = %+v, want %+v", tt.stat, got, tt.want)
+		}
+	}
+}
+
+func TestParseCmdline(t *testing.T) {
+	tests := []struct {
+		cmdline string
+		want    []string
+	}{
+		{cmdline: "", want: nil},
+		{cmdline: "\x00", want: nil},
+		{cmdline: "/opt/zoom/zoom\x00", want: []string{"/opt/zoom/zoom"}},
+		{cmdline: "zoom\x00--url=zoommtg://x\x00", want: []string{"zoom", "--url=zoommtg://x"}},
+		{cmdline: "zoom\x00\x00last\x00", want: []string{"zoom", "", "last"}},
+	}
+	for _, tt := range tests {
+		if got := parseCmdline([]byte(tt.cmdline)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCmdline(%q) = %q, want %q", tt.cmdline, got, tt.want)
+		}
+	}
+}
+
+func TestReadProcessesSkipsVanished(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, 1, 0, "init", "/sbin/init")
+	writeProc(t, root, 20, 1, "zoom", "/opt/zoom/zoom")
+
+	// Exited between listing /proc and reading stat.
+	if err := os.Mkdir(filepath.Join(root, "30"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Exited between reading stat and cmdline.
+	writeProc(t, root, 40, 20, "aomhost", "aomhost")
+	if err := os.Remove(filepath.Join(root, "40", "cmdline")); err != nil {
+		t.Fatal(err)
+	}
+	// Not a process directory.
+	if err := os.Mkdir(filepath.Join(root, "self"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	processes, err := readProcesses(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pids []int
+	for _, p := range processes {
+		pids = append(pids, p.PID)
+	}
+	if want := []int{1, 20}; !reflect.DeepEqual(pids, want) {
+		t.Errorf("pids = %v, want %v", pids, want)
+	}
+}
+
+func TestReadProcessesMissingRoot(t *testing.T) {
+	if _, err := readProcesses(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing proc root")
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	processes := []process{
+		{PID: 1, PPID: 0},
+		{PID: 2, PPID: 1},
+		{PID: 3, PPID: 2},
+		{PID: 4, PPID: 3},
+		{PID: 5, PPID: 1},
+		{PID: 6, PPID: 9},
+	}
+	var got []int
+	for _, p := range descendants(processes, 2) {
+		got = append(got, p.PID)
+	}
+	if want := []int{3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("descendants(2) = %v, want %v", got, want)
+	}
+}
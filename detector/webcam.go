@@ -0,0 +1,96 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// WebcamName is the detector name for Webcam.
+const WebcamName = "webcam"
+
+func init() {
+	Register(WebcamName, func(options map[string]interface{}) (MeetingDetector, error) {
+		d := &Webcam{ProcRoot: defaultProcRoot, Devices: `^/dev/video[0-9]+$`}
+		if err := decodeOptions(options, d); err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(d.Devices)
+		if err != nil {
+			return nil, fmt.Errorf("invalid devices pattern: %w", err)
+		}
+		d.devices = re
+		return d, nil
+	})
+}
+
+// Webcam detects a call by looking for a process holding a video device
+// open. Only processes whose file descriptors are readable by the current
+// user are considered.
+type Webcam struct {
+	// ProcRoot is where the proc filesystem is mounted.
+	ProcRoot string `mapstructure:"procRoot"`
+	// Devices is a regular expression matching the device paths to look for.
+	Devices string `mapstructure:"devices"`
+	// Ignore lists executables whose open devices don't count, e.g. a
+	// background face unlock service.
+	Ignore []string `mapstructure:"ignore"`
+
+	devices *regexp.Regexp
+}
+
+func (w *Webcam) Detect(ctx context.Context) (MeetingState, error) {
+	pids, err := listPIDs(w.ProcRoot)
+	if err != nil {
+		return MeetingState{}, fmt.Errorf("could not read process list: %w", err)
+	}
+	for _, pid := range pids {
+		device, ok := w.openDevice(pid)
+		if !ok {
+			continue
+		}
+		p, err := readProcess(w.ProcRoot, pid)
+		if err != nil {
+			// The process exited since its fds were read.
+			continue
+		}
+		if containsFold(w.Ignore, p.Executable()) {
+			continue
+		}
+		return MeetingState{
+			InMeeting: true,
+			OnCamera:  true,
+			Detector:  WebcamName,
+			Process:   p.Executable(),
+			Detail:    fmt.Sprintf("%s held open by %s (pid %d)", device, p.Executable(), pid),
+		}, nil
+	}
+	return MeetingState{Detector: WebcamName, Detail: "camera not in use"}, nil
+}
+
+// openDevice returns the first video device the process has open.
+func (w *Webcam) openDevice(pid int) (string, bool) {
+	fdDir := filepath.Join(w.ProcRoot, strconv.Itoa(pid), "fd")
+	fds, err := ioutil.ReadDir(fdDir)
+	if err != nil {
+		// Most commonly permission denied for other users' processes.
+		return "", false
+	}
+	for _, fd := range fds {
+		if fd.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil {
+			continue
+		}
+		if w.devices.MatchString(target) {
+			return target, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+package detector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// openFile adds a /proc/<pid>/fd/<fd> symlink to target under root.
+func openFile(t *testing.T, root string, pid, fd int, target string) {
+	t.Helper()
+	dir := filepath.Join(root, strconv.Itoa(pid), "fd")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, strconv.Itoa(fd))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebcamDetect(t *testing.T) {
+	type open struct {
+		pid, fd int
+		target  string
+	}
+	tests := []struct {
+		name    string
+		opens   []open
+		options map[string]interface{}
+		want    bool
+		process string
+		detail  string
+	}{
+		{
+			name:   "no devices open",
+			opens:  []open{{pid: 20, fd: 0, target: "/dev/null"}, {pid: 20, fd: 3, target: "socket:[1234]"}},
+			detail: "camera not in use",
+		},
+		{
+			name:    "zoom on camera",
+			opens:   []open{{pid: 20, fd: 0, target: "/dev/null"}, {pid: 20, fd: 31, target: "/dev/video0"}},
+			want:    true,
+			process: "zoom",
+			detail:  "/dev/video0 held open by zoom (pid 20)",
+		},
+		{
+			name:   "not a video device",
+			opens:  []open{{pid: 20, fd: 31, target: "/dev/video0-meta"}, {pid: 20, fd: 32, target: "/dev/snd/pcmC0D0c"}},
+			detail: "camera not in use",
+		},
+		{
+			name:    "ignored process",
+			opens:   []open{{pid: 10, fd: 4, target: "/dev/video2"}},
+			options: map[string]interface{}{"ignore": []string{"howdy"}},
+			detail:  "camera not in use",
+		},
+		{
+			name:    "ignored process and zoom",
+			opens:   []open{{pid: 10, fd: 4, target: "/dev/video2"}, {pid: 20, fd: 31, target: "/dev/video0"}},
+			options: map[string]interface{}{"ignore": []string{"HOWDY"}},
+			want:    true,
+			process: "zoom",
+			detail:  "/dev/video0 held open by zoom (pid 20)",
+		},
+		{
+			name:    "custom devices",
+			opens:   []open{{pid: 20, fd: 31, target: "/dev/v4l/by-id/usb-cam"}},
+			options: map[string]interface{}{"devices": "^/dev/v4l/"},
+			want:    true,
+			process: "zoom",
+			detail:  "/dev/v4l/by-id/usb-cam held open by zoom (pid 20)",
+		},
+		{
+			name:   "process exited after its fds were read",
+			opens:  []open{{pid: 40, fd: 5, target: "/dev/video0"}},
+			detail: "camera not in use",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeProc(t, root, 1, 0, "systemd", "/sbin/init")
+			writeProc(t, root, 20, 1, "zoom", "/opt/zoom/zoom")
+			writeProc(t, root, 10, 1, "howdy", "/usr/bin/howdy")
+			// pid 40 only has an fd directory left.
+			for _, o := range tt.opens {
+				openFile(t, root, o.pid, o.fd, o.target)
+			}
+
+			options := map[string]interface{}{"procRoot": root}
+			for k, v := range tt.options {
+				options[k] = v
+			}
+			d, err := New(WebcamName, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := d.Detect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.InMeeting != tt.want || got.OnCamera != tt.want {
+				t.Errorf("InMeeting, OnCamera = %v, %v, want %v", got.InMeeting, got.OnCamera, tt.want)
+			}
+			if got.Process != tt.process {
+				t.Errorf("Process = %q, want %q", got.Process, tt.process)
+			}
+			if got.Detail != tt.detail {
+				t.Errorf("Detail = %q, want %q", got.Detail, tt.detail)
+			}
+		})
+	}
+}
+
+func TestWebcamInvalidDevices(t *testing.T) {
+	if _, err := New(WebcamName, map[string]interface{}{"devices": "("}); err == nil {
+		t.Error("expected an error for an invalid devices pattern")
+	}
+}
@@ -0,0 +1,101 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ZoomLinuxName is the detector name for ZoomLinux.
+const ZoomLinuxName = "zoom-linux"
+
+func init() {
+	Register(ZoomLinuxName, func(options map[string]interface{}) (MeetingDetector, error) {
+		d := &ZoomLinux{
+			ProcRoot:         defaultProcRoot,
+			Executables:      []string{"zoom", "zoom.real", "ZoomLauncher"},
+			MeetingProcesses: []string{"aomhost", "cpthost"},
+		}
+		if err := decodeOptions(options, d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	})
+}
+
+// ZoomLinux detects Zoom meetings on Linux. The zoom binary runs for as long
+// as the client is open, so a meeting is only visible through the processes
+// it starts and their command lines. The process tree below every zoom
+// process is searched for one of MeetingProcesses, and the zoom processes and
+// their descendants for a command line containing one of MeetingArgs.
+type ZoomLinux struct {
+	// ProcRoot is where the proc filesystem is mounted.
+	ProcRoot string `mapstructure:"procRoot"`
+	// Executables are the names of the long-running Zoom client binary.
+	Executables []string `mapstructure:"executables"`
+	// MeetingProcesses are executables that Zoom only starts during a meeting.
+	MeetingProcesses []string `mapstructure:"meetingProcesses"`
+	// MeetingArgs are command line fragments that only appear on Zoom
+	// processes during a meeting.
+	MeetingArgs []string `mapstructure:"meetingArgs"`
+}
+
+func (z *ZoomLinux) Detect(ctx context.Context) (MeetingState, error) {
+	processes, err := readProcesses(z.ProcRoot)
+	if err != nil {
+		return MeetingState{}, fmt.Errorf("could not read process list: %w", err)
+	}
+
+	zoomRunning := false
+	for _, p := range processes {
+		if !containsFold(z.Executables, p.Executable()) {
+			continue
+		}
+		zoomRunning = true
+		// Joining from a link passes the meeting URL to zoom itself.
+		if arg, ok := z.meetingArg(p); ok {
+			return z.inMeeting(p, "meeting argument "+arg), nil
+		}
+		for _, child := range descendants(processes, p.PID) {
+			if containsFold(z.MeetingProcesses, child.Executable()) || containsFold(z.MeetingProcesses, child.Comm) {
+				return z.inMeeting(child, "meeting process "+child.Executable()), nil
+			}
+			if arg, ok := z.meetingArg(child); ok {
+				return z.inMeeting(child, "meeting argument "+arg), nil
+			}
+		}
+	}
+
+	if zoomRunning {
+		return MeetingState{Detector: ZoomLinuxName, Detail: "zoom open, not in a meeting"}, nil
+	}
+	return MeetingState{Detector: ZoomLinuxName, Detail: "zoom not running"}, nil
+}
+
+func (z *ZoomLinux) inMeeting(p process, reason string) MeetingState {
+	return MeetingState{
+		InMeeting: true,
+		Detector:  ZoomLinuxName,
+		Detail:    fmt.Sprintf("%s (pid %d)", reason, p.PID),
+	}
+}
+
+func (z *ZoomLinux) meetingArg(p process) (string, bool) {
+	for _, arg := range p.Cmdline {
+		for _, marker := range z.MeetingArgs {
+			if marker != "" && strings.Contains(arg, marker) {
+				return marker, true
+			}
+		}
+	}
+	return "", false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package detector
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestZoomLinuxDetect(t *testing.T) {
+	type proc struct {
+		pid, ppid int
+		comm      string
+		args      []string
+	}
+	base := []proc{
+		{pid: 1, ppid: 0, comm: "systemd", args: []string{"/sbin/init"}},
+		{pid: 10, ppid: 1, comm: "bash", args: []string{"bash"}},
+	}
+	tests := []struct {
+		name        string
+		procs       []proc
+		meetingArgs []string
+		want        bool
+		detail      string
+	}{
+		{
+			name:   "not running",
+			detail: "zoom not running",
+		},
+		{
+			name: "open",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "zoom", args: []string{"/opt/zoom/zoom"}},
+				{pid: 21, ppid: 20, comm: "zoom", args: []string{"/opt/zoom/zoom", "--type=utility"}},
+			},
+			detail: "zoom open, not in a meeting",
+		},
+		{
+			name: "meeting process",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "zoom", args: []string{"/opt/zoom/zoom"}},
+				{pid: 22, ppid: 20, comm: "aomhost", args: []string{"/opt/zoom/aomhost", "-ipc"}},
+			},
+			want:   true,
+			detail: "meeting process aomhost (pid 22)",
+		},
+		{
+			name: "meeting process found by comm",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "ZoomLauncher", args: []string{"/opt/zoom/ZoomLauncher"}},
+				{pid: 23, ppid: 20, comm: "cpthost", args: nil},
+			},
+			want:   true,
+			detail: "meeting process cpthost (pid 23)",
+		},
+		{
+			name: "meeting process grandchild",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "zoom", args: []string{"/opt/zoom/zoom"}},
+				{pid: 21, ppid: 20, comm: "sh", args: []string{"sh", "-c", "cpthost"}},
+				{pid: 24, ppid: 21, comm: "cpthost", args: []string{"/opt/zoom/cpthost"}},
+			},
+			want:   true,
+			detail: "meeting process cpthost (pid 24)",
+		},
+		{
+			name: "meeting process outside zoom",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "zoom", args: []string{"/opt/zoom/zoom"}},
+				{pid: 25, ppid: 10, comm: "aomhost", args: []string{"aomhost"}},
+			},
+			detail: "zoom open, not in a meeting",
+		},
+		{
+			name: "meeting argument on zoom",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "zoom", args: []string{"/opt/zoom/zoom", "--url=zoommtg://zoom.us/join?confno=123"}},
+			},
+			meetingArgs: []string{"zoommtg://"},
+			want:        true,
+			detail:      "meeting argument zoommtg:// (pid 20)",
+		},
+		{
+			name: "meeting argument on child",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "zoom", args: []string{"/opt/zoom/zoom"}},
+				{pid: 26, ppid: 20, comm: "zoom", args: []string{"/opt/zoom/zoom", "--meeting-id=123"}},
+			},
+			meetingArgs: []string{"--meeting-id"},
+			want:        true,
+			detail:      "meeting argument --meeting-id (pid 26)",
+		},
+		{
+			name: "meeting argument elsewhere",
+			procs: []proc{
+				{pid: 20, ppid: 10, comm: "zoom", args: []string{"/opt/zoom/zoom"}},
+				{pid: 27, ppid: 10, comm: "firefox", args: []string{"firefox", "zoommtg://zoom.us/join"}},
+			},
+			meetingArgs: []string{"zoommtg://"},
+			detail:      "zoom open, not in a meeting",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, p := range append(append([]proc{}, base...), tt.procs...) {
+				writeProc(t, root, p.pid, p.ppid, p.comm, p.args...)
+			}
+			d, err := New(ZoomLinuxName, map[string]interface{}{
+				"procRoot":    root,
+				"meetingArgs": tt.meetingArgs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := d.Detect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.InMeeting != tt.want {
+				t.Errorf("InMeeting = %v, want %v", got.InMeeting, tt.want)
+			}
+			if got.Detector != ZoomLinuxName {
+				t.Errorf("Detector = %q, want %q", got.Detector, ZoomLinuxName)
+			}
+			if got.Detail != tt.detail {
+				t.Errorf("Detail = %q, want %q", got.Detail, tt.detail)
+			}
+		})
+	}
+}
+
+func TestZoomLinuxMissingProcRoot(t *testing.T) {
+	d, err := New(ZoomLinuxName, map[string]interface{}{"procRoot": "/nonexistent/proc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Detect(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "could not read process list") {
+		t.Errorf("Detect error = %v, want a process list error", err)
+	}
+}
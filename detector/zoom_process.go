@@ -0,0 +1,44 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ps "github.com/mitchellh/go-ps"
+)
+
+// ZoomProcessName is the detector name for ZoomProcess.
+const ZoomProcessName = "zoom-process"
+
+func init() {
+	Register(ZoomProcessName, func(options map[string]interface{}) (MeetingDetector, error) {
+		d := &ZoomProcess{Process: "cpthost"}
+		if err := decodeOptions(options, d); err != nil {
+			return nil, err
+		}
+		return d, nil
+	})
+}
+
+// ZoomProcess detects a meeting by looking for a process that only runs while
+// a Zoom meeting is in progress.
+type ZoomProcess struct {
+	// Process is the executable name to look for, compared case-insensitively.
+	// NOTE: cpthost is the process that is running when a zoom meeting is in
+	// progress on Mac. It might not be the same for other systems.
+	Process string `mapstructure:"process"`
+}
+
+func (z *ZoomProcess) Detect(ctx context.Context) (MeetingState, error) {
+	processes, err := ps.Processes()
+	if err != nil {
+		return MeetingState{}, fmt.Errorf("could not get running process list: %w", err)
+	}
+	for _, proc := range processes {
+		if strings.EqualFold(proc.Executable(), z.Process) {
+			return MeetingState{InMeeting: true, Detector: ZoomProcessName}, nil
+		}
+	}
+	return MeetingState{}, nil
+}
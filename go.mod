@@ -7,6 +7,7 @@ require (
 	github.com/getlantern/systray v1.0.5
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/mitchellh/go-ps v1.0.0
+	github.com/mitchellh/mapstructure v1.1.2
 	github.com/spf13/viper v1.7.1
 	golang.org/x/sys v0.0.0-20220728004956-3c1f35247d10 // indirect
 )
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// hasDisplay reports whether there is a desktop session to show a tray icon
+// in. Only Linux can run without one.
+func hasDisplay() bool {
+	if runtime.GOOS != "linux" {
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// runHeadless runs the same loop as the tray app, logging to stdout, until
+// ctx is done.
+func runHeadless(ctx context.Context) {
+	fmt.Println("Running headless")
+	runLoop(ctx, nil)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+)
+
+// requiredScope is the token scope an account can't work without.
+const requiredScope = "users.profile:write"
+
+// authCheckTimeout bounds the auth.test call made for each account.
+const authCheckTimeout = 10 * time.Second
+
+// accountHealth is the result of checking an account's token.
+type accountHealth struct {
+	// Checked is set once auth.test got an answer from Slack.
+	Checked bool
+	Team    string
+	TeamID  string
+	UserID  string
+	// Err is why the account is broken, nil if it is healthy. If Checked is
+	// not set, it is why the check couldn't be made.
+	Err       error
+	CheckedAt time.Time
+}
+
+// broken reports whether the account should be skipped until it is fixed.
+func (h accountHealth) broken() bool {
+	return h.Checked && h.Err != nil
+}
+
+// healthRegistry holds the health of each account by name.
+type healthRegistry struct {
+	mu       sync.Mutex
+	accounts map[string]accountHealth
+}
+
+var health = &healthRegistry{accounts: map[string]accountHealth{}}
+
+func (r *healthRegistry) get(name string) accountHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.accounts[name]
+}
+
+func (r *healthRegistry) set(name string, h accountHealth) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts[name] = h
+}
+
+// checkAccounts checks every account's token with auth.test.
+func checkAccounts(accounts []Account) {
+	for _, account := range accounts {
+		h := checkAccount(account)
+		switch {
+		case h.broken():
+			fmt.Printf("Account %s is broken and will be skipped: %s\n", account.Name, h.Err)
+		case h.Err != nil:
+			fmt.Printf("Could not check account %s, will try again: %s\n", account.Name, h.Err)
+		default:
+			fmt.Printf("Account %s is signed in to %s as %s\n", account.Name, h.Team, h.UserID)
+		}
+		health.set(account.Name, h)
+	}
+}
+
+// recheckAccounts checks the accounts whose last check couldn't reach Slack.
+func recheckAccounts(accounts []Account) {
+	var unchecked []Account
+	for _, account := range accounts {
+		if !health.get(account.Name).Checked {
+			unchecked = append(unchecked, account)
+		}
+	}
+	if len(unchecked) > 0 {
+		checkAccounts(unchecked)
+	}
+}
+
+func checkAccount(account Account) accountHealth {
+	ctx, cancel := context.WithTimeout(context.Background(), authCheckTimeout)
+	defer cancel()
+
+	h := accountHealth{CheckedAt: time.Now()}
+	if account.tokenErr != nil {
+		h.Checked = true
+		h.Err = account.tokenErr
+		return h
+	}
+
+	id, err := account.client.AuthTest(ctx)
+	var slackErr *slack.Error
+	switch {
+	case errors.As(err, &slackErr) && slackErr.Code != "ratelimited":
+		// Slack rejected the token, e.g. invalid_auth or token_revoked.
+		h.Checked = true
+		h.Err = slackErr
+		return h
+	case err != nil:
+		h.Err = err
+		return h
+	}
+
+	h.Checked = true
+	h.Team, h.TeamID, h.UserID = id.Team, id.TeamID, id.UserID
+	if !id.HasScope(requiredScope) {
+		h.Err = fmt.Errorf("token is missing the %s scope", requiredScope)
+	}
+	return h
+}
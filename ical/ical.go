@@ -0,0 +1,309 @@
+// Package ical parses the subset of iCalendar (RFC 5545) needed to tell
+// whether a calendar is busy at a given time: VEVENTs with their recurrence
+// rules, exception dates and overridden instances.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Calendar is a parsed VCALENDAR.
+type Calendar struct {
+	Events []Event
+	// Unsupported describes events that were skipped because their
+	// recurrence rule uses features this package does not implement.
+	Unsupported []string
+}
+
+// Event is a single VEVENT. Recurring events carry their rule and are
+// expanded by Occurrences.
+type Event struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+	// AllDay is set when DTSTART is a DATE rather than a DATE-TIME.
+	AllDay bool
+	// Transparent events (TRANSP:TRANSPARENT) don't block time.
+	Transparent bool
+	// Cancelled events (STATUS:CANCELLED) don't block time.
+	Cancelled bool
+
+	RRule   *RRule
+	RDates  []time.Time
+	ExDates []time.Time
+	// RecurrenceID is set on an event that replaces a single instance of a
+	// recurring event with the same UID.
+	RecurrenceID time.Time
+}
+
+// Busy reports whether the event blocks time on a calendar.
+func (e Event) Busy() bool {
+	return !e.Transparent && !e.Cancelled
+}
+
+// Occurrence is a single instance of an event.
+type Occurrence struct {
+	Event *Event
+	Start time.Time
+	End   time.Time
+}
+
+// Parse reads a calendar. Time values without a zone, and those with a
+// TZID that is not in the system time zone database, are read in loc.
+func Parse(r io.Reader, loc *time.Location) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &Calendar{}
+	var (
+		event    *Event
+		duration time.Duration
+		hasEnd   bool
+		skip     error
+		depth    []string
+	)
+	for n, raw := range lines {
+		if raw == "" {
+			continue
+		}
+		prop, err := parseLine(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			depth = append(depth, strings.ToUpper(prop.Value))
+			if len(depth) == 2 && depth[1] == "VEVENT" {
+				event = &Event{}
+				duration, hasEnd, skip = 0, false, nil
+			}
+			continue
+		case "END":
+			if len(depth) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", n+1, prop.Value)
+			}
+			if len(depth) == 2 && event != nil {
+				if event.Start.IsZero() {
+					return nil, fmt.Errorf("line %d: event %q has no DTSTART", n+1, event.UID)
+				}
+				if !hasEnd {
+					switch {
+					case duration != 0:
+						event.End = event.Start.Add(duration)
+					case event.AllDay:
+						event.End = event.Start.AddDate(0, 0, 1)
+					default:
+						event.End = event.Start
+					}
+				}
+				if skip != nil {
+					cal.Unsupported = append(cal.Unsupported, fmt.Sprintf("%q: %s", event.Summary, skip))
+				} else {
+					cal.Events = append(cal.Events, *event)
+				}
+				event = nil
+			}
+			depth = depth[:len(depth)-1]
+			continue
+		}
+
+		// Only properties directly inside a VEVENT are of interest, this
+		// skips VALARMs and VTIMEZONE definitions.
+		if event == nil || len(depth) != 2 {
+			continue
+		}
+		switch prop.Name {
+		case "UID":
+			event.UID = prop.Value
+		case "SUMMARY":
+			event.Summary = unescapeText(prop.Value)
+		case "DTSTART":
+			event.Start, event.AllDay, err = parseTime(prop, loc)
+		case "DTEND":
+			event.End, _, err = parseTime(prop, loc)
+			hasEnd = true
+		case "DURATION":
+			duration, err = parseDuration(prop.Value)
+		case "TRANSP":
+			event.Transparent = strings.EqualFold(prop.Value, "TRANSPARENT")
+		case "STATUS":
+			event.Cancelled = strings.EqualFold(prop.Value, "CANCELLED")
+		case "RRULE":
+			event.RRule, err = ParseRRule(prop.Value, loc)
+			if unsupported := (ErrUnsupportedRule{}); errors.As(err, &unsupported) {
+				skip, err = err, nil
+			}
+		case "RDATE":
+			var times []time.Time
+			times, err = parseTimeList(prop, loc)
+			event.RDates = append(event.RDates, times...)
+		case "EXDATE":
+			var times []time.Time
+			times, err = parseTimeList(prop, loc)
+			event.ExDates = append(event.ExDates, times...)
+		case "RECURRENCE-ID":
+			event.RecurrenceID, _, err = parseTime(prop, loc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n+1, prop.Name, err)
+		}
+	}
+	if len(depth) != 0 {
+		return nil, fmt.Errorf("unterminated BEGIN:%s", depth[len(depth)-1])
+	}
+	return cal, nil
+}
+
+// Busy returns the busy occurrences that are in progress at t.
+func (c *Calendar) Busy(t time.Time) []Occurrence {
+	// Instances replaced by a RECURRENCE-ID event are dropped from the
+	// recurring event and the replacement is used instead.
+	overridden := map[string]map[int64]bool{}
+	for _, e := range c.Events {
+		if e.RecurrenceID.IsZero() {
+			continue
+		}
+		if overridden[e.UID] == nil {
+			overridden[e.UID] = map[int64]bool{}
+		}
+		overridden[e.UID][e.RecurrenceID.Unix()] = true
+	}
+
+	var busy []Occurrence
+	for i := range c.Events {
+		e := &c.Events[i]
+		if !e.Busy() {
+			continue
+		}
+		var skip map[int64]bool
+		if e.RecurrenceID.IsZero() {
+			skip = overridden[e.UID]
+		}
+		// Occurrences that started within the event's length before t may
+		// still be running.
+		length := e.End.Sub(e.Start)
+		for _, o := range e.Occurrences(t.Add(-length), t) {
+			if skip[o.Start.Unix()] {
+				continue
+			}
+			if !o.Start.After(t) && o.End.After(t) {
+				busy = append(busy, o)
+			}
+		}
+	}
+	return busy
+}
+
+// Occurrences returns the instances of the event starting in [from, to].
+func (e *Event) Occurrences(from, to time.Time) []Occurrence {
+	length := e.End.Sub(e.Start)
+
+	var starts []time.Time
+	if e.RRule == nil {
+		starts = append(starts, e.Start)
+	} else {
+		starts = e.RRule.Expand(e.Start, to)
+	}
+	starts = append(starts, e.RDates...)
+
+	excluded := make(map[int64]bool, len(e.ExDates))
+	for _, ex := range e.ExDates {
+		excluded[ex.Unix()] = true
+	}
+
+	var out []Occurrence
+	seen := map[int64]bool{}
+	for _, start := range starts {
+		key := start.Unix()
+		if excluded[key] || seen[key] || start.Before(from) || start.After(to) {
+			continue
+		}
+		seen[key] = true
+		out = append(out, Occurrence{Event: e, Start: start, End: start.Add(length)})
+	}
+	return out
+}
+
+// property is a single content line: NAME;PARAM=VALUE:VALUE
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// unfold joins folded content lines (those continued with a leading space or
+// tab) and strips line endings.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseLine(line string) (property, error) {
+	// The value starts at the first colon that is not inside a quoted
+	// parameter value.
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("malformed content line %q", line)
+	}
+
+	prop := property{Params: map[string]string{}, Value: line[colon+1:]}
+	parts := splitUnquoted(line[:colon], ';')
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		prop.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+	return prop, nil
+}
+
+func splitUnquoted(s string, sep rune) []string {
+	var (
+		parts    []string
+		inQuotes bool
+		start    int
+	)
+	for i, r := range s {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == sep && !inQuotes {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,150 @@
+package ical
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFile(t *testing.T, name string) *Calendar {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cal, err := Parse(f, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal
+}
+
+func TestParse(t *testing.T) {
+	cal := parseFile(t, "meetings.ics")
+
+	var summaries []string
+	events := map[string]Event{}
+	for _, e := range cal.Events {
+		summaries = append(summaries, e.Summary)
+		events[e.Summary] = e
+	}
+	want := []string{"Standup", "Standup (moved)", "Focus time", "Cancelled sync", "Offsite", "Lunch, with team"}
+	if !reflect.DeepEqual(summaries, want) {
+		t.Fatalf("events = %q, want %q", summaries, want)
+	}
+
+	if len(cal.Unsupported) != 1 || !strings.Contains(cal.Unsupported[0], `"Pomodoro"`) || !strings.Contains(cal.Unsupported[0], "FREQ=HOURLY") {
+		t.Errorf("Unsupported = %q, want the Pomodoro event", cal.Unsupported)
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standup := events["Standup"]
+	if want := time.Date(2024, 1, 1, 9, 0, 0, 0, newYork); !standup.Start.Equal(want) || standup.Start.Location().String() != "America/New_York" {
+		t.Errorf("Standup start = %s, want %s", standup.Start, want)
+	}
+	if standup.RRule == nil || standup.RRule.Freq != "WEEKLY" || len(standup.RRule.ByDay) != 3 {
+		t.Errorf("Standup rule = %+v", standup.RRule)
+	}
+	if len(standup.ExDates) != 1 {
+		t.Errorf("Standup exdates = %v", standup.ExDates)
+	}
+	if moved := events["Standup (moved)"]; moved.RecurrenceID.IsZero() || moved.UID != standup.UID {
+		t.Errorf("moved standup = %+v, want a RECURRENCE-ID override", moved)
+	}
+
+	if e := events["Focus time"]; !e.Transparent || e.Busy() {
+		t.Errorf("Focus time transparent = %v, busy = %v", e.Transparent, e.Busy())
+	}
+	if e := events["Cancelled sync"]; !e.Cancelled || e.Busy() || e.End.Sub(e.Start) != time.Hour {
+		t.Errorf("Cancelled sync = %+v", e)
+	}
+	if e := events["Offsite"]; !e.AllDay || !e.End.Equal(e.Start.AddDate(0, 0, 1)) {
+		t.Errorf("Offsite all day = %v, %s to %s", e.AllDay, e.Start, e.End)
+	}
+	// The VALARM's DTSTART must not replace the event's.
+	if e := events["Lunch, with team"]; !e.Start.Equal(time.Date(2024, 1, 2, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("Lunch start = %s", e.Start)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing dtstart": "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nEND:VEVENT\nEND:VCALENDAR\n",
+		"unterminated":    "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20240101T090000Z\n",
+		"unexpected end":  "END:VCALENDAR\n",
+		"malformed line":  "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART\nEND:VEVENT\nEND:VCALENDAR\n",
+		"bad time":        "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2024-01-01\nEND:VEVENT\nEND:VCALENDAR\n",
+		"bad duration":    "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20240101T090000Z\nDURATION:1H\nEND:VEVENT\nEND:VCALENDAR\n",
+	}
+	for name, input := range tests {
+		if _, err := Parse(strings.NewReader(input), time.UTC); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBusy(t *testing.T) {
+	cal := parseFile(t, "meetings.ics")
+	tests := []struct {
+		name string
+		at   time.Time
+		want []string
+	}{
+		{name: "first standup starts", at: date(2024, 1, 1, 14, 0), want: []string{"Standup"}},
+		{name: "standup next to a free event", at: date(2024, 1, 1, 14, 10), want: []string{"Standup"}},
+		{name: "after standup", at: date(2024, 1, 1, 14, 15)},
+		{name: "not a standup day", at: date(2024, 1, 2, 14, 5)},
+		{name: "excluded standup", at: date(2024, 1, 3, 14, 5)},
+		{name: "moved standup original time", at: date(2024, 1, 5, 14, 5)},
+		{name: "moved standup new time", at: date(2024, 1, 5, 15, 10), want: []string{"Standup (moved)"}},
+		{name: "last standup before until", at: date(2024, 1, 31, 14, 5), want: []string{"Standup"}},
+		{name: "after until", at: date(2024, 2, 2, 14, 5)},
+		{name: "cancelled", at: date(2024, 1, 2, 15, 30)},
+		{name: "lunch", at: date(2024, 1, 2, 17, 59), want: []string{"Lunch, with team"}},
+		{name: "lunch end is exclusive", at: date(2024, 1, 2, 18, 0)},
+		{name: "all day", at: date(2024, 1, 10, 23, 59), want: []string{"Offsite"}},
+		{name: "after all day", at: date(2024, 1, 11, 0, 0)},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, o := range cal.Busy(tt.at) {
+			got = append(got, o.Event.Summary)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Busy(%s) = %q, want %q", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT1H30M": 90 * time.Minute,
+		"P1D":     24 * time.Hour,
+		"-P1W":    -7 * 24 * time.Hour,
+		"+PT15S":  15 * time.Second,
+		"P1DT2H":  26 * time.Hour,
+	}
+	for value, want := range tests {
+		got, err := parseDuration(value)
+		if err != nil || got != want {
+			t.Errorf("parseDuration(%q) = %s, %v, want %s", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "P", "PT", "1H", "PT1", "P1H", "PT1D"} {
+		if _, err := parseDuration(value); err == nil {
+			t.Errorf("parseDuration(%q): expected an error", value)
+		}
+	}
+}
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
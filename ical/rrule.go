@@ -0,0 +1,325 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds rule expansion so a malformed rule can't loop forever.
+const maxPeriods = 100000
+
+// ErrUnsupportedRule is returned by ParseRRule for valid rules that use
+// parts this package does not implement.
+type ErrUnsupportedRule struct {
+	Part string
+}
+
+func (e ErrUnsupportedRule) Error() string {
+	return "unsupported recurrence rule part " + e.Part
+}
+
+// WeekdayNum is a BYDAY entry, e.g. MO, 2TU or -1FR. N is zero when the
+// entry applies to every matching weekday.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// RRule is a recurrence rule. DAILY, WEEKLY, MONTHLY and YEARLY rules with
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST are supported.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRRule parses an RRULE value. A date-only UNTIL is read in loc and
+// covers the whole day.
+func ParseRRule(value string, loc *time.Location) (*RRule, error) {
+	r := &RRule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed rule part %q", part)
+		}
+		key, val := strings.ToUpper(kv[0]), kv[1]
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = strings.ToUpper(val)
+			switch r.Freq {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+			default:
+				return nil, ErrUnsupportedRule{Part: "FREQ=" + r.Freq}
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(val)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			var isDate bool
+			r.Until, isDate, err = parseTimeValue(val, false, loc)
+			if isDate {
+				r.Until = r.Until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				var wd WeekdayNum
+				wd, err = parseWeekdayNum(day)
+				if err != nil {
+					break
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				var n int
+				n, err = strconv.Atoi(day)
+				if err != nil {
+					break
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(val, ",") {
+				var n int
+				n, err = strconv.Atoi(month)
+				if err != nil {
+					break
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			wd, ok := weekdays[strings.ToUpper(val)]
+			if !ok {
+				err = fmt.Errorf("unknown weekday")
+			}
+			r.WeekStart = wd
+		default:
+			return nil, ErrUnsupportedRule{Part: key}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rule part %s=%s: %w", key, val, err)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("rule %q has no FREQ", value)
+	}
+	return r, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("malformed weekday %q", s)
+	}
+	wd, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("malformed weekday %q", s)
+	}
+	n := 0
+	if prefix := s[:len(s)-2]; prefix != "" {
+		var err error
+		if n, err = strconv.Atoi(prefix); err != nil {
+			return WeekdayNum{}, fmt.Errorf("malformed weekday %q", s)
+		}
+	}
+	return WeekdayNum{Weekday: wd, N: n}, nil
+}
+
+// Expand returns the start times generated by the rule for an event that
+// starts at dtstart, up to and including to. COUNT and UNTIL are honoured;
+// exception dates are left to the caller.
+func (r *RRule) Expand(dtstart, to time.Time) []time.Time {
+	var (
+		out   []time.Time
+		count int
+	)
+	for k := 0; k < maxPeriods; k++ {
+		periodStart, candidates := r.period(dtstart, k)
+		if periodStart.After(to) || (!r.Until.IsZero() && periodStart.After(r.Until)) {
+			break
+		}
+		for _, c := range candidates {
+			if c.Before(dtstart) {
+				continue
+			}
+			if c.After(to) || (!r.Until.IsZero() && c.After(r.Until)) {
+				return out
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return out
+			}
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// period returns the start of the k-th period after dtstart and the sorted
+// instance candidates within it.
+func (r *RRule) period(dtstart time.Time, k int) (time.Time, []time.Time) {
+	y, m, d := dtstart.Date()
+	n := k * r.Interval
+
+	var (
+		start      time.Time
+		candidates []time.Time
+	)
+	switch r.Freq {
+	case "DAILY":
+		start = r.at(dtstart, y, m, d+n)
+		candidates = []time.Time{start}
+	case "WEEKLY":
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		start = r.at(dtstart, y, m, d-offset+7*n)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []WeekdayNum{{Weekday: dtstart.Weekday()}}
+		}
+		for _, wd := range days {
+			shift := (int(wd.Weekday) - int(r.WeekStart) + 7) % 7
+			sy, sm, sd := start.Date()
+			candidates = append(candidates, r.at(dtstart, sy, sm, sd+shift))
+		}
+	case "MONTHLY":
+		start = r.at(dtstart, y, m+time.Month(n), 1)
+		candidates = r.monthDays(dtstart, start.Year(), start.Month())
+	case "YEARLY":
+		start = r.at(dtstart, y+n, time.January, 1)
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			candidates = append(candidates, r.monthDays(dtstart, y+n, month)...)
+		}
+	}
+
+	filtered := candidates[:0]
+	for _, c := range candidates {
+		if r.matches(c) {
+			filtered = append(filtered, c)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Before(filtered[j]) })
+	return start, filtered
+}
+
+// monthDays returns the candidate days of a month for MONTHLY and YEARLY
+// rules.
+func (r *RRule) monthDays(dtstart time.Time, year int, month time.Month) []time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	var days []int
+	switch {
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			days = append(days, weekdaysInMonth(year, month, last, wd)...)
+		}
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md = last + md + 1
+			}
+			days = append(days, md)
+		}
+	default:
+		days = []int{dtstart.Day()}
+	}
+
+	var out []time.Time
+	for _, day := range days {
+		// Days that don't exist in this month, e.g. the 31st, are skipped
+		// rather than rolled over into the next month.
+		if day < 1 || day > last {
+			continue
+		}
+		out = append(out, r.at(dtstart, year, month, day))
+	}
+	return out
+}
+
+// weekdaysInMonth returns the days of the month that match a BYDAY entry.
+func weekdaysInMonth(year int, month time.Month, last int, wd WeekdayNum) []int {
+	var days []int
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+	for day := 1 + (int(wd.Weekday)-int(first)+7)%7; day <= last; day += 7 {
+		days = append(days, day)
+	}
+	switch {
+	case wd.N > 0 && wd.N <= len(days):
+		return days[wd.N-1 : wd.N]
+	case wd.N < 0 && -wd.N <= len(days):
+		return days[len(days)+wd.N : len(days)+wd.N+1]
+	case wd.N != 0:
+		return nil
+	}
+	return days
+}
+
+// matches applies the BY* parts that limit, rather than expand, the
+// instances of the rule's frequency.
+func (r *RRule) matches(t time.Time) bool {
+	if len(r.ByMonth) > 0 && r.Freq != "YEARLY" {
+		ok := false
+		for _, m := range r.ByMonth {
+			ok = ok || t.Month() == m
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(r.ByDay) > 0 && r.Freq == "DAILY" {
+		ok := false
+		for _, wd := range r.ByDay {
+			ok = ok || t.Weekday() == wd.Weekday
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(r.ByMonthDay) > 0 && (r.Freq == "DAILY" || r.Freq == "WEEKLY" || len(r.ByDay) > 0) {
+		last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		ok := false
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md = last + md + 1
+			}
+			ok = ok || t.Day() == md
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// at returns the given day at dtstart's wall clock time and location.
+func (r *RRule) at(dtstart time.Time, year int, month time.Month, day int) time.Time {
+	h, min, sec := dtstart.Clock()
+	return time.Date(year, month, day, h, min, sec, 0, dtstart.Location())
+}
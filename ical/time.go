@@ -0,0 +1,123 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout      = "20060102"
+	dateTimeLayout  = "20060102T150405"
+	utcDateTimeForm = "20060102T150405Z"
+)
+
+// parseTime parses a DATE or DATE-TIME property value, honouring the TZID
+// and VALUE parameters. It reports whether the value was a DATE.
+func parseTime(prop property, loc *time.Location) (time.Time, bool, error) {
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		loc = location(tzid, loc)
+	}
+	return parseTimeValue(prop.Value, prop.Params["VALUE"] == "DATE", loc)
+}
+
+// parseTimeList parses the comma separated values of RDATE and EXDATE.
+func parseTimeList(prop property, loc *time.Location) ([]time.Time, error) {
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		loc = location(tzid, loc)
+	}
+	isDate := prop.Params["VALUE"] == "DATE"
+	var times []time.Time
+	for _, value := range strings.Split(prop.Value, ",") {
+		if value == "" {
+			continue
+		}
+		t, _, err := parseTimeValue(value, isDate, loc)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+func parseTimeValue(value string, isDate bool, loc *time.Location) (time.Time, bool, error) {
+	switch {
+	case isDate || len(value) == len(dateLayout):
+		t, err := time.ParseInLocation(dateLayout, value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse(utcDateTimeForm, value)
+		return t, false, err
+	default:
+		t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+		return t, false, err
+	}
+}
+
+// location resolves a TZID. Calendars exported from some clients use
+// Windows zone names or a "/" prefixed id; those that can't be resolved fall
+// back to the default location.
+func location(tzid string, fallback *time.Location) *time.Location {
+	if loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+		return loc
+	}
+	return fallback
+}
+
+// parseDuration parses an RFC 5545 duration such as PT1H30M, P1D or -P1W.
+func parseDuration(value string) (time.Duration, error) {
+	s := value
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("malformed duration %q", value)
+	}
+	s = s[1:]
+
+	var (
+		d      time.Duration
+		inTime bool
+		num    string
+	)
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("malformed duration %q", value)
+		}
+		num = ""
+		unit := time.Duration(n)
+		switch {
+		case r == 'W' && !inTime:
+			d += unit * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			d += unit * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += unit * time.Hour
+		case r == 'M' && inTime:
+			d += unit * time.Minute
+		case r == 'S' && inTime:
+			d += unit * time.Second
+		default:
+			return 0, fmt.Errorf("malformed duration %q", value)
+		}
+	}
+	if num != "" {
+		return 0, fmt.Errorf("malformed duration %q", value)
+	}
+	return sign * d, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultAuthorizeURL = "https://slack.com/oauth/v2/authorize"
+	defaultLoginPort    = 8976
+	loginTimeout        = 5 * time.Minute
+)
+
+// loginScopes are the user scopes requested when signing in.
+var loginScopes = []string{"users.profile:read", "users.profile:write", "users:write", "dnd:write"}
+
+// runLogin signs in to a workspace with the OAuth v2 user token flow and adds
+// the account to the config file.
+func runLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	clientID := fs.String("client-id", "", "client ID of your Slack app (default: oauth.clientId from the config file)")
+	clientSecret := fs.String("client-secret", "", "client secret of your Slack app (default: oauth.clientSecret from the config file)")
+	name := fs.String("name", "", "account name (default: the workspace name)")
+	port := fs.Int("port", defaultLoginPort, "port of the local redirect listener")
+	redirectURL := fs.String("redirect-url", "", "redirect URL registered with the Slack app (default: http://localhost:<port>/callback)")
+	authorizeURL := fs.String("authorize-url", defaultAuthorizeURL, "URL of Slack's OAuth authorize page")
+	apiURL := fs.String("api-url", slack.DefaultBaseURL, "Slack Web API root, used for the oauth.v2.access token exchange")
+	noBrowser := fs.Bool("no-browser", false, "only print the sign in URL instead of opening a browser")
+	keyring := fs.String("keyring", "", "keep the token in the system keyring as `SERVICE/ACCOUNT`, instead of in plain text in the config file")
+	fs.Parse(args)
+
+	if *keyring != "" {
+		if _, _, err := splitKeyringRef(*keyring); err != nil {
+			return err
+		}
+	}
+
+	// The config file is optional here, login can create it.
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return err
+		}
+	}
+	if *clientID == "" {
+		*clientID = viper.GetString("oauth.clientId")
+	}
+	if *clientSecret == "" {
+		*clientSecret = viper.GetString("oauth.clientSecret")
+	}
+	if *clientID == "" || *clientSecret == "" {
+		return fmt.Errorf("the client ID and secret of your Slack app are required, see --help")
+	}
+	if *redirectURL == "" {
+		*redirectURL = fmt.Sprintf("http://localhost:%d/callback", *port)
+	}
+
+	state, err := randomState()
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", *port))
+	if err != nil {
+		return fmt.Errorf("starting redirect listener: %w", err)
+	}
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	server := &http.Server{Handler: callbackHandler(state, codes, errs)}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authURL := *authorizeURL + "?" + url.Values{
+		"client_id":    {*clientID},
+		"user_scope":   {strings.Join(loginScopes, ",")},
+		"redirect_uri": {*redirectURL},
+		"state":        {state},
+	}.Encode()
+	fmt.Printf("Sign in to Slack at:\n\n  %s\n\n", authURL)
+	if !*noBrowser {
+		if err := openBrowser(authURL); err != nil {
+			fmt.Printf("Could not open a browser, open the URL above yourself: %s\n", err)
+		}
+	}
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-errs:
+		return err
+	case <-time.After(loginTimeout):
+		return fmt.Errorf("timed out waiting for the redirect from Slack")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client := slack.New("")
+	client.BaseURL = *apiURL
+	access, err := client.OAuthV2Access(ctx, *clientID, *clientSecret, code, *redirectURL)
+	if err != nil {
+		return fmt.Errorf("exchanging code for a token: %w", err)
+	}
+
+	if *name == "" {
+		*name = access.Team.Name
+	}
+	token := access.AuthedUser.AccessToken
+	if *keyring != "" {
+		if err := keyringStore(*keyring, token); err != nil {
+			return fmt.Errorf("saving token in the keyring: %w", err)
+		}
+		token = "keyring:" + *keyring
+	}
+	path, err := saveAccount(*name, token, *apiURL)
+	if err != nil {
+		return fmt.Errorf("saving account: %w", err)
+	}
+	fmt.Printf("Signed in to %s, account %q saved to %s\n", access.Team.Name, *name, path)
+	if *keyring == "" {
+		fmt.Printf("Warning: the token is stored in plain text in %s, anyone who can read the file can use it. Sign in with --keyring to keep it in the system keyring instead.\n", path)
+	}
+	return nil
+}
+
+// callbackHandler receives the OAuth redirect and passes on the code.
+func callbackHandler(state string, codes chan<- string, errs chan<- error) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("state") != state:
+			http.Error(w, "Invalid state, please start the sign in again.", http.StatusBadRequest)
+			return
+		case query.Get("error") != "":
+			http.Error(w, "Sign in failed: "+query.Get("error"), http.StatusBadRequest)
+			errs <- fmt.Errorf("slack returned %s", query.Get("error"))
+			return
+		case query.Get("code") == "":
+			http.Error(w, "No code in the redirect.", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Signed in to Slack, you can close this window.")
+		select {
+		case codes <- query.Get("code"):
+		default:
+		}
+	})
+	return mux
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func openBrowser(u string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", u).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", u).Start()
+	default:
+		return exec.Command("xdg-open", u).Start()
+	}
+}
+
+// saveAccount adds the account to the config file, or replaces the token of
+// an account with the same name. It returns the path of the config file.
+func saveAccount(name, token, apiURL string) (string, error) {
+	// A separate viper instance, so only what is in the file is written back
+	// and not the defaults of the one the app reads from.
+	v := viper.New()
+	path := viper.ConfigFileUsed()
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return "", newConfigError(path, err)
+		}
+	}
+
+	accounts, _ := v.Get("accounts").([]interface{})
+	found := false
+	for _, a := range accounts {
+		if m, ok := a.(map[interface{}]interface{}); ok && m["name"] == name {
+			m["token"] = token
+			found = true
+		}
+		if m, ok := a.(map[string]interface{}); ok && m["name"] == name {
+			m["token"] = token
+			found = true
+		}
+	}
+	if !found {
+		account := map[string]interface{}{"name": name, "token": token}
+		if apiURL != slack.DefaultBaseURL {
+			account["api_base_url"] = apiURL
+		}
+		accounts = append(accounts, account)
+	}
+	v.Set("accounts", accounts)
+
+	if path != "" {
+		return path, v.WriteConfig()
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Join(home, ".zoom-slack-status.yml")
+	return path, v.SafeWriteConfigAs(path)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// useConfigFile points the app's viper instance at a new config file with the
+// given contents.
+func useConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".zoom-slack-status.yml")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if err := setupConfig(); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSaveAccount(t *testing.T) {
+	path := useConfigFile(t, `accounts:
+- name: home
+  token: xoxp-home
+- name: work
+  token: xoxp-old
+`)
+
+	if got, err := saveAccount("work", "xoxp-new", slack.DefaultBaseURL); err != nil || got != path {
+		t.Fatalf("saveAccount = %q, %v", got, err)
+	}
+	if got, err := saveAccount("test", "xoxp-test", "http://127.0.0.1:9999/api/"); err != nil || got != path {
+		t.Fatalf("saveAccount = %q, %v", got, err)
+	}
+
+	written := readFile(t, path)
+	for _, want := range []string{"xoxp-home", "xoxp-new", "xoxp-test", "api_base_url: http://127.0.0.1:9999/api/"} {
+		if !strings.Contains(written, want) {
+			t.Errorf("config file is missing %q:\n%s", want, written)
+		}
+	}
+	// Only what was in the file is written back, not the app's defaults.
+	for _, unwanted := range []string{"xoxp-old", "interval", "statusexpiry", "updatetimeout"} {
+		if strings.Contains(strings.ToLower(written), unwanted) {
+			t.Errorf("config file contains %q:\n%s", unwanted, written)
+		}
+	}
+	if viper.IsSet("accounts") && len(viper.Get("accounts").([]interface{})) != 2 {
+		t.Error("saveAccount changed the app's config in memory")
+	}
+}
+
+func TestSaveAccountCreatesConfig(t *testing.T) {
+	home := t.TempDir()
+	setenv(t, "HOME", home)
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path, err := saveAccount("work", "xoxp-work", slack.DefaultBaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".zoom-slack-status.yml"); path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+	if written := readFile(t, path); !strings.Contains(written, "xoxp-work") || strings.Contains(written, "api_base_url") {
+		t.Errorf("config file:\n%s", written)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/detector"
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+)
+
+// switchDetector reports a meeting while it is switched on.
+type switchDetector struct {
+	mu        sync.Mutex
+	inMeeting bool
+}
+
+func (d *switchDetector) set(inMeeting bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inMeeting = inMeeting
+}
+
+func (d *switchDetector) Detect(ctx context.Context) (detector.MeetingState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return detector.MeetingState{InMeeting: d.inMeeting, Detector: "switch"}, nil
+}
+
+// withDetector makes cfg use d as its only detector.
+func withDetector(cfg *Config, d detector.MeetingDetector) *Config {
+	cfg.priorities = presence.Priorities(nil)
+	cfg.detectors = []stateDetector{{detector: d}}
+	return cfg
+}
+
+// startLoop runs runLoop until the test ends, and returns the transitions it
+// shows.
+func startLoop(t *testing.T) <-chan presence.Transition {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	shown := make(chan presence.Transition, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runLoop(ctx, func(cfg *Config, t presence.Transition) {
+			shown <- t
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return shown
+}
+
+// nextState waits for the loop to finish a check.
+func nextState(t *testing.T, shown <-chan presence.Transition, timeout time.Duration) presence.Transition {
+	t.Helper()
+	select {
+	case tr := <-shown:
+		return tr
+	case <-time.After(timeout):
+		t.Fatalf("no check within %s", timeout)
+		return presence.Transition{}
+	}
+}
+
+func TestRunLoopMeetingToAvailable(t *testing.T) {
+	fake := newTestWorkspace(t)
+	lunch := slack.Profile{StatusText: "Lunch", StatusEmoji: ":pizza:"}
+	account := testAccount(fake, "work", lunch)
+	account.Statuses[presence.Meeting].Presence = "away"
+	account.Statuses[presence.Meeting].DNDMinutes = 30
+
+	meeting := &switchDetector{inMeeting: true}
+	cfg := withDetector(testConfig(account), meeting)
+	cfg.Interval = time.Hour
+	config.set(cfg)
+
+	shown := startLoop(t)
+	if tr := nextState(t, shown, 5*time.Second); tr.To.State != presence.Meeting || !tr.Changed {
+		t.Fatalf("transition = %+v, want a change to meeting", tr)
+	}
+	user, _ := fake.User(account.Token)
+	if user.Profile.StatusText != meetingStatus.StatusText || user.Profile.StatusExpiration == 0 {
+		t.Errorf("profile = %+v, want the meeting status with an expiration", user.Profile)
+	}
+	if user.Presence != "away" || user.SnoozeEnd.IsZero() {
+		t.Errorf("presence = %q, snooze until %s, want away and snoozed", user.Presence, user.SnoozeEnd)
+	}
+	if saved := store.account(account.Name); saved.Snapshot == nil || saved.Snapshot.StatusText != "Lunch" {
+		t.Errorf("snapshot = %+v, want the lunch status", saved.Snapshot)
+	}
+
+	meeting.set(false)
+	control.refresh()
+	if tr := nextState(t, shown, 5*time.Second); tr.To.State != presence.Available || !tr.Changed {
+		t.Fatalf("transition = %+v, want a change to available", tr)
+	}
+	user, _ = fake.User(account.Token)
+	if user.Profile != lunch {
+		t.Errorf("profile = %+v, want the lunch status restored", user.Profile)
+	}
+	if user.Presence != "auto" || !user.SnoozeEnd.IsZero() {
+		t.Errorf("presence = %q, snooze until %s, want auto and not snoozed", user.Presence, user.SnoozeEnd)
+	}
+	if saved := store.account(account.Name); saved.Snapshot != nil || saved.State != presence.Available {
+		t.Errorf("saved = %+v, want available without a snapshot", saved)
+	}
+	if res, ok := results.get(account.Name); !ok || res.Err != nil || res.State != presence.Available {
+		t.Errorf("result = %+v, want a successful update to available", res)
+	}
+	if _, current, _ := control.last(); current.State != presence.Available {
+		t.Errorf("last check = %s, want available", current.State)
+	}
+}
+
+func TestDisabledAccountReleased(t *testing.T) {
+	fake := newTestWorkspace(t)
+	lunch := slack.Profile{StatusText: "Lunch", StatusEmoji: ":pizza:"}
+	account := testAccount(fake, "work", lunch)
+	account.Statuses[presence.Meeting].Presence = "away"
+	account.Statuses[presence.Meeting].DNDMinutes = 30
+	ctx := context.Background()
+	meeting := presence.Signal{State: presence.Meeting}
+
+	if !setState(ctx, testConfig(account), meeting, false) {
+		t.Fatal("could not start the meeting")
+	}
+
+	// Turned off from the menu mid-meeting.
+	enabled := false
+	account.Enabled = &enabled
+	cfg := testConfig(account)
+	if !setState(ctx, cfg, meeting, false) {
+		t.Fatal("could not release the disabled account")
+	}
+	user, _ := fake.User(account.Token)
+	if user.Profile != lunch || user.Presence != "auto" || !user.SnoozeEnd.IsZero() {
+		t.Errorf("user = %+v, want the lunch status restored, presence auto and no snooze", user)
+	}
+	if saved := store.account(account.Name); saved.held() || saved.Snapshot != nil {
+		t.Errorf("saved = %+v, want nothing held", saved)
+	}
+
+	// Only once: later states and the reset on exit leave it alone.
+	calls := len(fake.Calls(""))
+	fake.SetProfile(account.Token, slack.Profile{StatusText: "Vacation"})
+	setState(ctx, cfg, meeting, false)
+	setState(ctx, cfg, meeting, true)
+	setState(ctx, cfg, presence.Signal{State: presence.Available}, false)
+	if n := len(fake.Calls("")); n != calls {
+		t.Errorf("%d more calls for the disabled account, want none", n-calls)
+	}
+	if updates.len() != 0 {
+		t.Errorf("%d updates pending for the disabled account", updates.len())
+	}
+}
+
+func TestDisabledAccountDropsPendingUpdate(t *testing.T) {
+	fake := newTestWorkspace(t)
+	account := testAccount(fake, "work", slack.Profile{})
+	enabled := false
+	account.Enabled = &enabled
+
+	// A failed update from before the account was turned off.
+	updates.want(account.Name, presence.Signal{State: presence.Meeting}, false)
+	if !setState(context.Background(), testConfig(account), presence.Signal{State: presence.Meeting}, false) {
+		t.Fatal("setState failed")
+	}
+	if updates.len() != 0 || len(fake.Calls("users.profile.set")) != 0 {
+		t.Errorf("the stale update was applied to the disabled account")
+	}
+}
+
+func TestReloadOnlyUpdatesChangedAccounts(t *testing.T) {
+	fake := newTestWorkspace(t)
+	a := testAccount(fake, "a", slack.Profile{})
+	b := testAccount(fake, "b", slack.Profile{})
+	d := &switchDetector{}
+	reload := func(a, b Account) {
+		cfg := withDetector(testConfig(a, b), d)
+		cfg.Interval = time.Hour
+		config.set(cfg)
+	}
+	profileSets := func(account Account) int {
+		n := 0
+		for _, c := range fake.Calls("users.profile.set") {
+			if c.Token == account.Token {
+				n++
+			}
+		}
+		return n
+	}
+
+	reload(a, b)
+	shown := startLoop(t)
+	nextState(t, shown, 5*time.Second)
+	vacation := slack.Profile{StatusText: "On vacation tomorrow", StatusEmoji: ":palm_tree:"}
+	fake.SetProfile(b.Token, vacation)
+	setsA, setsB := profileSets(a), profileSets(b)
+
+	// Turned off from the menu, which rewrites the config file.
+	disabled, enabled := false, true
+	a.Enabled = &disabled
+	reload(a, b)
+	nextState(t, shown, 5*time.Second)
+	if n := profileSets(a) - setsA; n != 0 {
+		t.Errorf("%d status updates for the disabled account, want none", n)
+	}
+
+	// Turned back on, it is updated again.
+	a.Enabled = &enabled
+	reload(a, b)
+	nextState(t, shown, 5*time.Second)
+	if n := profileSets(a) - setsA; n != 1 {
+		t.Errorf("%d status updates for the enabled account, want 1", n)
+	}
+
+	// b's settings changed, but it is already available and the status set
+	// by hand is kept.
+	b.Statuses = map[string]*SlackStatus{
+		presence.Meeting:   {StatusText: "Busy", StatusEmoji: ":no_entry:"},
+		presence.Available: {},
+	}
+	reload(a, b)
+	nextState(t, shown, 5*time.Second)
+
+	if n := profileSets(b) - setsB; n != 0 {
+		t.Errorf("%d status updates for the other account, want none", n)
+	}
+	if got := profileOf(t, fake, b); got != vacation {
+		t.Errorf("other account profile = %+v, want the status set by hand", got)
+	}
+}
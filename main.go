@@ -1,75 +1,100 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"math"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/caitlinelfring/zoom-slack-status/icons"
+	"github.com/caitlinelfring/zoom-slack-status/presence"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/getlantern/systray"
 	homedir "github.com/mitchellh/go-homedir"
-	ps "github.com/mitchellh/go-ps"
 	"github.com/spf13/viper"
 )
 
-type SlackStatus struct {
-	StatusText  string `mapstructure:"status_text" json:"status_text"`
-	StatusEmoji string `mapstructure:"status_emoji" json:"status_emoji"`
+// stateTitles are the menu titles of the built-in states.
+var stateTitles = map[string]string{
+	presence.Available:  "Not In Meeting",
+	presence.Meeting:    "In Meeting",
+	presence.Camera:     "On Camera",
+	presence.Presenting: "Presenting",
+	presence.Focus:      "Focusing",
+	presence.Lunch:      "At Lunch",
+	presence.Offline:    "Offline",
 }
 
-type Account struct {
-	Name            string       `mapstructure:"name"`
-	Token           string       `mapstructure:"token"`
-	MeetingStatus   *SlackStatus `mapstructure:"meetingStatus"`
-	NoMeetingStatus *SlackStatus `mapstructure:"noMeetingStatus"`
-}
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [options] [command]
 
-type Config struct {
-	Accounts []Account     `mapstructure:"accounts"`
-	Interval time.Duration `mapstructure:"interval"`
-}
+Without a command, runs in the system tray and keeps your Slack status in sync.
 
-type SlackResponse struct {
-	Ok      bool   `json:"ok"`
-	Error   string `json:"error"`
-	Warning string `json:"warning"`
-	// Other fields ignored:
-	// 		response_metadata
-	//		profile
+Commands:
+  status           show the state of the running instance, or check for meetings
+  set STATE        set the status for a state on all accounts
+  clear            set all accounts back to their noMeetingStatus
+  once             check for meetings and update the accounts once, e.g. from cron
+  login            add a Slack account by signing in with OAuth
+  validate [file]  check a config file, without contacting Slack
+
+Options:
+`, os.Args[0])
+	flag.PrintDefaults()
 }
 
-var (
-	defaultMeetingStatus = SlackStatus{
-		StatusText:  "In a meeting",
-		StatusEmoji: ":zoom:",
-	}
-	defaultNoMeetingStatus               = SlackStatus{}
-	defaultInterval        time.Duration = 60 * time.Second
+func main() {
+	flag.Usage = usage
+	headless := flag.Bool("headless", !hasDisplay(), "run without a system tray icon, until interrupted")
+	flag.Parse()
 
-	config        = Config{}
-	configChanged = false
-)
+	if err := setupConfig(); err != nil {
+		panic(err)
+	}
 
-// Receiver functions for outputting Config and Account structures as strings.
-// Custom handling is necessary to output the contents of structs embedded via pointers.
-func (c Config) String() string {
-	return fmt.Sprintf("{Accounts:%v Interval:%v}", c.Accounts, c.Interval)
+	args := flag.Args()
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		run(*headless)
+	case "status":
+		exitOnError(cmd, runStatus(args[1:]))
+	case "set":
+		exitOnError(cmd, runSet(args[1:]))
+	case "clear":
+		exitOnError(cmd, runClear(args[1:]))
+	case "once":
+		exitOnError(cmd, runOnce(args[1:]))
+	case "login":
+		exitOnError(cmd, runLogin(args[1:]))
+	case "validate":
+		exitOnError(cmd, runValidate(args[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
 }
 
-func (a Account) String() string {
-	return fmt.Sprintf("{Name:%v Token:%v MeetingStatus:%+v NoMeetingStatus:%+v}", a.Name, a.Token, a.MeetingStatus, a.NoMeetingStatus)
+// exitOnError exits with status 1 if a command failed.
+func exitOnError(cmd string, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd, err)
+		os.Exit(1)
+	}
 }
 
-func main() {
+// setupConfig tells viper where to find the config file.
+func setupConfig() error {
 	home, err := homedir.Dir()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	viper.AddConfigPath(home)
@@ -77,183 +102,524 @@ func main() {
 	viper.SetConfigName(".zoom-slack-status")
 
 	viper.SetDefault("interval", defaultInterval)
+	viper.SetDefault("statusExpiry", defaultStatusExpiry)
+	viper.SetDefault("updateTimeout", defaultUpdateTimeout)
+	return nil
+}
+
+// run keeps the Slack statuses in sync, in the tray until it is quit, or
+// headless until the process is interrupted.
+func run(headless bool) {
+	if err := loadInConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load config: %s\n", err)
+		os.Exit(1)
+	}
 
-	loadInConfig()
+	loadState()
+	control.restore(store.override())
 
 	viper.WatchConfig()
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		fmt.Printf("Config file changed: %s, operation: %s\n", e.Name, e.Op)
-		loadInConfig()
+		reloadConfig()
 	})
 
-	systray.Run(onReady, onExit)
-}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
 
-func loadInConfig() {
-	if err := viper.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("fatal error config file: %s", err))
-	}
-
-	cfg := Config{}
-	if err := viper.Unmarshal(&cfg); err != nil {
-		panic(err)
+	if headless {
+		notifyShutdown(stop)
+		runHeadless(ctx)
+		onExit()
+		return
 	}
 
-	// Set default status values if not configured.
-	for i := range cfg.Accounts {
-		if cfg.Accounts[i].MeetingStatus == nil {
-			cfg.Accounts[i].MeetingStatus = &defaultMeetingStatus
+	notifyShutdown(systray.Quit)
+	loopDone := make(chan struct{})
+	systray.Run(func() {
+		defer close(loopDone)
+		onReady(ctx)
+	}, func() {
+		// Let the loop finish its current update before resetting.
+		stop()
+		select {
+		case <-loopDone:
+		case <-time.After(shutdownTimeout):
 		}
-		if cfg.Accounts[i].NoMeetingStatus == nil {
-			cfg.Accounts[i].NoMeetingStatus = &defaultNoMeetingStatus
-		}
-	}
-
-	// Update global configuration.
-	config = cfg
-	configChanged = true
-
-	fmt.Printf("Configuration loaded:\n%v\n\n", config)
+		onExit()
+	})
 }
 
-func onReady() {
+func onReady(ctx context.Context) {
 	systray.SetTooltip("Zoom Status")
 	systray.SetIcon(icons.Free)
 
 	menuStatus := systray.AddMenuItem("Status: Not In Meeting", "Not In Meeting")
 	menuStatus.Disable()
 
+	menuConfig := newConfigMenu()
+	menuAccounts := newAccountsMenu()
+
 	systray.AddSeparator()
+	menuOverride := newOverrideMenu()
 
 	systray.AddSeparator()
 	mQuit := systray.AddMenuItem("Quit Zoom Status", "Quit Zoom Status")
 	go func() {
 		<-mQuit.ClickedCh
 		systray.Quit()
-		os.Exit(0)
 	}()
 
-	inMeeting := false
+	runLoop(ctx, func(cfg *Config, t presence.Transition) {
+		if t.To.State == presence.Available {
+			systray.SetIcon(icons.Free)
+		} else {
+			systray.SetIcon(icons.Busy)
+		}
+		o, overridden := control.activeOverride(time.Now())
+		status := "Status: " + stateTitle(t.To.State)
+		switch {
+		case overridden && o.Paused:
+			status += " (paused until " + o.until() + ")"
+		case overridden:
+			status += " (marked until " + o.until() + ")"
+		}
+		menuStatus.SetTitle(status)
+		menuOverride.update(overridden)
+		menuConfig.update(config.err())
+		menuAccounts.update(cfg.Accounts)
+	})
+}
+
+// runLoop checks for meetings and updates the accounts every interval, and
+// right after the config is reloaded, until ctx is done. show, if set, is
+// called with the outcome of every check.
+func runLoop(ctx context.Context, show func(*Config, presence.Transition)) {
+	// The config was just loaded, there is no need to wake up for that.
+	select {
+	case <-config.changes():
+	default:
+	}
+
+	var cfg *Config
+	engine := presence.NewEngine(config.get().priorities)
+	reloaded, paused := true, false
 
 	for {
-		wasInMeeting := inMeeting
-		inMeeting = checkForMeeting()
+		// prev is the config before a reload, nil on the first pass.
+		var prev *Config
+		if reloaded {
+			prev, cfg = cfg, config.get()
+			engine.SetPriorities(cfg.priorities)
+			api.apply(cfg.API)
+		}
 
-		if inMeeting {
-			if !wasInMeeting || configChanged {
-				setInMeeting(true)
-			} else {
-				fmt.Println("Status already set to in meeting")
-			}
+		recheckAccounts(cfg.Accounts)
+
+		detected := checkForMeeting(ctx, cfg)
+		if ctx.Err() != nil {
+			return
+		}
+		var t presence.Transition
+		if o, ok := control.activeOverride(time.Now()); ok && o.Paused {
+			fmt.Printf("Automation paused until %s\n", o.until())
+			current := engine.Current()
+			t = presence.Transition{From: current, To: current}
+			paused = true
 		} else {
-			if wasInMeeting || configChanged {
-				setInMeeting(false)
+			signals := detected
+			if ok {
+				fmt.Printf("State overridden to %s until %s\n", o.State, o.until())
+				signals = []presence.Signal{{State: o.State}}
+			}
+			t = engine.Step(signals)
+
+			// Statuses may have been changed by hand while paused.
+			if t.Changed || paused || reloaded && prev == nil {
+				setState(ctx, cfg, t.To, false)
+			} else if reloaded {
+				setChangedAccounts(ctx, prev, cfg, t.To)
+			} else if cfg.refreshesExpiration(t.To) {
+				fmt.Printf("Refreshing %s status expiration\n", t.To.State)
+				setState(ctx, cfg, t.To, true)
+			} else if updates.len() > 0 {
+				fmt.Println("Retrying failed status updates")
+				syncAccounts(ctx, cfg)
 			} else {
-				fmt.Println("Status already set to not in meeting")
+				fmt.Printf("Status already set to %s\n", t.To.State)
 			}
+			paused = false
 		}
+		control.checked(detected, t.To, time.Now())
 
-		if inMeeting {
-			menuStatus.SetTitle("Status: In Meeting")
-		} else {
-			menuStatus.SetTitle("Status: Not In Meeting")
+		if show != nil {
+			show(cfg, t)
 		}
 
-		configChanged = false
+		// Check again when an override ends, rather than up to an interval
+		// later.
+		wait := cfg.Interval
+		if o, ok := control.activeOverride(time.Now()); ok && time.Until(o.Until) < wait {
+			wait = time.Until(o.Until)
+		}
 
-		time.Sleep(config.Interval)
+		reloaded = false
+		select {
+		case <-ctx.Done():
+			return
+		case <-config.changes():
+			// A failed reload only wakes the loop to show the error.
+			reloaded = config.get() != cfg
+		case <-control.wakeups():
+		case <-time.After(wait):
+		}
 	}
 }
 
+// onExit stops the API and resets the statuses, and exits with
+// exitResetFailed if some could not be reset. The tray app calls it from
+// systray's exit callback, because on macOS the process exits as soon as that
+// returns, without systray.Run returning.
 func onExit() {
-	setInMeeting(false)
+	api.stop()
+	if !resetStatuses() {
+		os.Exit(exitResetFailed)
+	}
 }
 
-func checkForMeeting() bool {
+func stateTitle(state string) string {
+	if title, ok := stateTitles[state]; ok {
+		return title
+	}
+	return state
+}
+
+// checkForMeeting runs every configured detector and returns the states they
+// report as active.
+func checkForMeeting(ctx context.Context, cfg *Config) []presence.Signal {
 	fmt.Println("Checking for active meetings...")
 
-	processes, err := ps.Processes()
-	if err != nil {
-		fmt.Printf("Could not get running process list: %s\n", err)
-		return false
-	}
-	for _, proc := range processes {
-		// NOTE: This is the process that is running when a zoom meeting is
-		// in progress on Mac. It might not be the same for other systems
-		if strings.ToLower(proc.Executable()) == "cpthost" {
-			return true
+	var signals []presence.Signal
+	for _, d := range cfg.detectors {
+		state, err := d.detector.Detect(ctx)
+		if err != nil {
+			fmt.Printf("Could not check for meetings: %s\n", err)
+			continue
 		}
+		if !state.InMeeting {
+			continue
+		}
+
+		name := d.state
+		if name == "" {
+			name = presence.Meeting
+			if state.OnCamera {
+				name = presence.Camera
+			}
+		}
+		fmt.Printf("State %s detected by %s: %s\n", name, state.Detector, state.Detail)
+		signals = append(signals, presence.Signal{State: name, Meeting: state})
 	}
-	return false
+	return signals
 }
 
-func setInMeeting(inMeeting bool) {
-	fmt.Printf("Setting status to in meeting: %t\n", inMeeting)
+// refreshesExpiration reports whether the state's status has a rolling
+// expiration that must be pushed back on every tick.
+func (c *Config) refreshesExpiration(signal presence.Signal) bool {
+	return signal.State != presence.Available && c.StatusExpiry > 0 && signal.Meeting.End.IsZero()
+}
 
-	if inMeeting {
-		systray.SetIcon(icons.Busy)
-	} else {
-		systray.SetIcon(icons.Free)
+// statusExpiration returns when a status for the given state should expire.
+// Meeting statuses end with the calendar event if its end is known, and
+// otherwise after StatusExpiry, but never before the next tick could refresh
+// them. Zero means the status doesn't expire.
+func (c *Config) statusExpiration(signal presence.Signal, now time.Time) int64 {
+	if signal.State == presence.Available || c.StatusExpiry <= 0 {
+		return 0
 	}
+	if end := signal.Meeting.End; end.After(now) {
+		return end.Unix()
+	}
+	expiry := c.StatusExpiry
+	if min := 2 * c.Interval; expiry < min {
+		expiry = min
+	}
+	return now.Add(expiry).Unix()
+}
 
-	// Set status for all accounts
-	for _, account := range config.Accounts {
-		fmt.Println("Setting slack status for " + account.Name)
+// setState sets the status for the given state on all accounts. When refresh
+// is set, the state hasn't changed and only the expiration is pushed back. It
+// reports whether every account was updated.
+func setState(ctx context.Context, cfg *Config, signal presence.Signal, refresh bool) bool {
+	fmt.Printf("Setting status to %s\n", signal.State)
 
-		var status *SlackStatus
+	// Set status for all accounts
+	updates.retain(cfg.Accounts)
+	for _, account := range cfg.Accounts {
+		// Broken and disabled accounts are skipped quietly, they are shown
+		// in the menu.
+		if health.get(account.Name).broken() {
+			continue
+		}
+		if !account.enabled() {
+			releaseDisabled(account)
+			continue
+		}
+		updates.want(account.Name, signal, refresh)
+	}
+	return syncAccounts(ctx, cfg)
+}
 
-		if inMeeting {
-			status = account.MeetingStatus
-		} else {
-			status = account.NoMeetingStatus
+// setChangedAccounts sets the status of the accounts whose settings changed
+// in a config reload, such as one that was just enabled or disabled. The
+// other accounts are left alone, so statuses set on them by hand stay.
+func setChangedAccounts(ctx context.Context, prev, cfg *Config, signal presence.Signal) bool {
+	updates.retain(cfg.Accounts)
+	for _, account := range cfg.Accounts {
+		if !accountChanged(prev, cfg, account) || health.get(account.Name).broken() {
+			continue
+		}
+		if !account.enabled() {
+			releaseDisabled(account)
+			continue
 		}
+		fmt.Printf("Settings for %s changed, setting status to %s\n", account.Name, signal.State)
+		updates.want(account.Name, signal, false)
+	}
+	return syncAccounts(ctx, cfg)
+}
 
-		if err := setSlackProfile(*status, account.Token); err != nil {
-			fmt.Printf("Failed to set slack profile for %s: %s\n", account.Name, err.Error())
+// accountChanged reports whether the account is new in cfg, or anything that
+// affects the status written to it is different from prev.
+func accountChanged(prev, cfg *Config, account Account) bool {
+	if prev.StatusExpiry != cfg.StatusExpiry {
+		return true
+	}
+	for _, old := range prev.Accounts {
+		if old.Name == account.Name {
+			return old.Token != account.Token ||
+				old.APIBaseURL != account.APIBaseURL ||
+				old.RespectManualStatus != account.RespectManualStatus ||
+				old.enabled() != account.enabled() ||
+				!reflect.DeepEqual(old.Statuses, account.Statuses)
 		}
 	}
+	return true
 }
 
-func setSlackProfile(status SlackStatus, token string) error {
-	var profile = struct {
-		Profile SlackStatus `json:"profile"`
-	}{status}
+// releaseDisabled queues moving a disabled account back to available, once,
+// if it was turned off while in a meeting, so its status, presence and Do Not
+// Disturb aren't left behind. Any other update for it is dropped. It reports
+// whether an update was queued.
+func releaseDisabled(account Account) bool {
+	if !store.account(account.Name).held() {
+		updates.drop(account.Name)
+		return false
+	}
+	fmt.Printf("Account %s is disabled, setting it back to available\n", account.Name)
+	updates.want(account.Name, presence.Signal{State: presence.Available}, false)
+	return true
+}
 
-	statusBytes, err := json.Marshal(profile)
-	if err != nil {
-		return err
+// syncAccounts applies the pending update of every account. Accounts are
+// updated concurrently, each within the update timeout, so a workspace that
+// hangs doesn't hold up the others. Updates that fail stay queued for the
+// next attempt. It reports whether every update was applied.
+func syncAccounts(ctx context.Context, cfg *Config) bool {
+	start := time.Now()
+
+	var (
+		wg              sync.WaitGroup
+		mu              sync.Mutex
+		updated, failed int
+	)
+	for _, account := range cfg.Accounts {
+		update, ok := updates.get(account.Name)
+		if !ok || health.get(account.Name).broken() {
+			continue
+		}
+
+		wg.Add(1)
+		go func(account Account, update pendingUpdate) {
+			defer wg.Done()
+
+			res := updateAccount(ctx, cfg, account, update)
+			res.log(account.Name)
+			results.set(account.Name, res)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if res.Err != nil {
+				failed++
+				return
+			}
+			updated++
+			updates.done(account.Name, update)
+		}(account, update)
 	}
+	wg.Wait()
 
-	req, err := http.NewRequest("POST", "https://slack.com/api/users.profile.set", bytes.NewBuffer(statusBytes))
-	if err != nil {
-		return err
+	if updated+failed > 0 {
+		fmt.Printf("Updated %d of %d accounts in %s\n", updated, updated+failed, time.Since(start).Round(time.Millisecond))
 	}
+	return failed == 0
+}
 
-	// Add proper headers
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	req.Header.Add("Authorization", "Bearer "+token)
+// updateAccount applies a pending update to one account and reports how it
+// went.
+func updateAccount(ctx context.Context, cfg *Config, account Account, update pendingUpdate) accountResult {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, cfg.UpdateTimeout)
+	defer cancel()
+	ctx, warnings := withWarnings(ctx)
+
+	last, _ := results.get(account.Name)
+	res := accountResult{
+		State:     update.signal.State,
+		Status:    last.Status,
+		At:        start,
+		LastErr:   last.LastErr,
+		LastErrAt: last.LastErrAt,
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	var errs []string
+	status, err := setAccountState(ctx, cfg, account, update)
 	if err != nil {
-		return err
+		errs = append(errs, "profile: "+err.Error())
+	} else if status != nil {
+		res.Status = status
+	}
+	if !update.refresh {
+		if err := setAccountPresence(ctx, account, update.signal); err != nil {
+			errs = append(errs, "presence: "+err.Error())
+		}
 	}
-	defer resp.Body.Close()
 
-	fmt.Println("Response Status:", resp.Status)
+	res.Latency = time.Since(start)
+	res.Warnings = warnings.get()
+	if len(errs) > 0 {
+		res.Err = errors.New(strings.Join(errs, "; "))
+		res.LastErr, res.LastErrAt = res.Err, start
+	}
+	return res
+}
 
-	r := SlackResponse{}
-	err = json.NewDecoder(resp.Body).Decode(&r)
-	if err != nil {
-		return err
+// setAccountPresence applies the presence and Do Not Disturb settings of the
+// given state. Whatever an earlier state turned on is turned off again when
+// the new state doesn't set it.
+func setAccountPresence(ctx context.Context, account Account, signal presence.Signal) error {
+	saved := store.account(account.Name)
+	status := account.statusFor(signal.State)
+
+	var errs []string
+	switch {
+	case status.Presence != "":
+		if err := account.client.SetPresence(ctx, status.Presence); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			saved.Presence = status.Presence
+		}
+	case saved.Presence == "away":
+		if err := account.client.SetPresence(ctx, "auto"); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			saved.Presence = ""
+		}
+	}
+
+	switch {
+	case status.DNDMinutes > 0:
+		minutes := status.DNDMinutes
+		// Snooze until the end of a calendar meeting, if known.
+		if end := signal.Meeting.End; end.After(time.Now()) {
+			minutes = int(math.Ceil(time.Until(end).Minutes()))
+		}
+		if err := account.client.SetSnooze(ctx, minutes); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			saved.Snoozed = true
+		}
+	case saved.Snoozed:
+		if err := account.client.EndSnooze(ctx); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			saved.Snoozed = false
+		}
+	}
+
+	if err := store.setAccount(account.Name, saved); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
 	}
+	return nil
+}
 
-	if len(r.Warning) > 0 {
-		fmt.Println("Warning: ", r.Warning)
+// setAccountState sets an account's status for the given state. The status
+// the user had before a meeting is saved when the meeting starts and restored
+// when it ends, unless the user changed it by hand in the meantime. Accounts
+// with RespectManualStatus also leave statuses set by hand alone at every
+// other transition. A refresh never overwrites a status set by hand, nor does
+// setting an account that is already available to available again, and a
+// forced update always does. It returns the status written, or nil if the
+// status was left alone.
+func setAccountState(ctx context.Context, cfg *Config, account Account, update pendingUpdate) (*SlackStatus, error) {
+	signal := update.signal
+	now := time.Now()
+	saved := store.account(account.Name)
+	status := account.statusFor(signal.State).expand(signal.Meeting)
+	status.StatusExpiration = cfg.statusExpiration(signal, now)
+
+	profile, getErr := account.client.GetProfile(ctx)
+	current := statusFromProfile(profile)
+	if getErr != nil {
+		warn(ctx, "could not get current status: %s", getErr)
+	}
+	// The status was changed by hand if it isn't the one last set here, and
+	// wasn't just cleared by Slack when it expired.
+	expiredBySlack := saved.Written != nil && saved.Written.expired(now) && current.empty()
+	manual := getErr == nil && saved.Written != nil && !current.same(*saved.Written) && !expiredBySlack
+
+	switch {
+	case signal.State != presence.Available && saved.Snapshot == nil:
+		// A meeting is starting. Keep the user's status, including one
+		// restored here after the last meeting, but not a meeting status
+		// left behind by an earlier run.
+		leftover := saved.Written != nil && saved.State != presence.Available && !manual
+		if getErr == nil && !leftover {
+			saved.Snapshot = &current
+		}
+	case update.force:
+		// Written as asked, the snapshot is dropped below when clearing.
+	case signal.State == presence.Available && saved.Snapshot != nil:
+		if getErr != nil {
+			return nil, fmt.Errorf("could not check status before restoring it: %w", getErr)
+		}
+		if manual {
+			warn(ctx, "status was changed by hand during the meeting, not restoring %+v", *saved.Snapshot)
+			saved.Snapshot = nil
+			saved.State = signal.State
+			return nil, store.setAccount(account.Name, saved)
+		}
+		if !saved.Snapshot.empty() && !saved.Snapshot.expired(now) {
+			fmt.Printf("Restoring previous status for %s: %+v\n", account.Name, *saved.Snapshot)
+			status = *saved.Snapshot
+		}
+	case manual && (account.RespectManualStatus || update.refresh || signal.State == presence.Available && saved.State == presence.Available):
+		warn(ctx, "status was changed by hand to %+v, not updating it", current)
+		saved.State = signal.State
+		return nil, store.setAccount(account.Name, saved)
 	}
 
-	if !r.Ok {
-		err = fmt.Errorf(r.Error)
+	if err := account.client.SetProfile(ctx, status.profile()); err != nil {
+		return nil, err
 	}
 
-	return err
+	saved.Written = &status
+	saved.State = signal.State
+	if signal.State == presence.Available {
+		saved.Snapshot = nil
+	}
+	return &status, store.setAccount(account.Name, saved)
 }
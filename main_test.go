@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+	"github.com/caitlinelfring/zoom-slack-status/slackfake"
+)
+
+var meetingStatus = SlackStatus{StatusText: "In a meeting", StatusEmoji: ":zoom:"}
+
+// newTestWorkspace starts a fake Slack server and resets the global state
+// for a test.
+func newTestWorkspace(t *testing.T) *slackfake.Server {
+	t.Helper()
+	fake := slackfake.New()
+	t.Cleanup(fake.Close)
+
+	store = &stateStore{Accounts: map[string]accountState{}}
+	updates = &updateQueue{pending: map[string]pendingUpdate{}}
+	results = &resultRegistry{accounts: map[string]accountResult{}}
+	health = &healthRegistry{accounts: map[string]accountHealth{}}
+	control = newController()
+	config = newConfigStore()
+	return fake
+}
+
+// testAccount adds a user with the given status to the fake server and
+// returns an account for it.
+func testAccount(fake *slackfake.Server, name string, profile slack.Profile) Account {
+	token := "xoxp-" + name
+	fake.AddUser(token, slackfake.User{ID: "U" + name, Name: name, Team: "Example", Profile: profile})
+	meeting := meetingStatus
+	return Account{
+		Name:  name,
+		Token: token,
+		Statuses: map[string]*SlackStatus{
+			presence.Meeting:   &meeting,
+			presence.Available: {},
+		},
+		client: newSlackClient(token, fake.APIURL()),
+	}
+}
+
+func testConfig(accounts ...Account) *Config {
+	return &Config{
+		Accounts:      accounts,
+		Interval:      time.Minute,
+		StatusExpiry:  10 * time.Minute,
+		UpdateTimeout: 5 * time.Second,
+	}
+}
+
+// profileOf returns the fake user's current status.
+func profileOf(t *testing.T, fake *slackfake.Server, account Account) slack.Profile {
+	t.Helper()
+	user, ok := fake.User(account.Token)
+	if !ok {
+		t.Fatalf("no fake user for %s", account.Name)
+	}
+	return user.Profile
+}
+
+func TestSetAccountStateRestoresAfterSlackExpiredStatus(t *testing.T) {
+	fake := newTestWorkspace(t)
+	account := testAccount(fake, "work", slack.Profile{})
+	cfg := testConfig(account)
+
+	// The meeting status expired in Slack, so the profile is empty again,
+	// before the meeting ended.
+	expired := meetingStatus
+	expired.StatusExpiration = time.Now().Add(-time.Minute).Unix()
+	lunch := SlackStatus{StatusText: "Lunch", StatusEmoji: ":pizza:"}
+	store.Accounts[account.Name] = accountState{Snapshot: &lunch, Written: &expired, State: presence.Meeting}
+
+	status, err := setAccountState(context.Background(), cfg, account, pendingUpdate{signal: presence.Signal{State: presence.Available}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == nil || !status.same(lunch) {
+		t.Errorf("set %+v, want the snapshot restored", status)
+	}
+	if got := profileOf(t, fake, account); got.StatusText != "Lunch" || got.StatusEmoji != ":pizza:" {
+		t.Errorf("profile = %+v, want the snapshot", got)
+	}
+	if saved := store.account(account.Name); saved.Snapshot != nil || saved.State != presence.Available {
+		t.Errorf("saved = %+v, want the snapshot used up", saved)
+	}
+}
+
+func TestSetAccountStateRefreshesAfterSlackExpiredStatus(t *testing.T) {
+	for _, respect := range []bool{false, true} {
+		fake := newTestWorkspace(t)
+		account := testAccount(fake, "work", slack.Profile{})
+		account.RespectManualStatus = respect
+		cfg := testConfig(account)
+
+		expired := meetingStatus
+		expired.StatusExpiration = time.Now().Add(-time.Minute).Unix()
+		store.Accounts[account.Name] = accountState{Snapshot: &SlackStatus{}, Written: &expired, State: presence.Meeting}
+
+		signal := presence.Signal{State: presence.Meeting}
+		status, err := setAccountState(context.Background(), cfg, account, pendingUpdate{signal: signal, refresh: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status == nil {
+			t.Fatalf("respectManualStatus %v: status not refreshed after Slack expired it", respect)
+		}
+		got := profileOf(t, fake, account)
+		if got.StatusText != meetingStatus.StatusText || got.StatusExpiration <= time.Now().Unix() {
+			t.Errorf("respectManualStatus %v: profile = %+v, want the meeting status with a new expiration", respect, got)
+		}
+	}
+}
+
+func TestSetAccountStateLeavesManualStatus(t *testing.T) {
+	fake := newTestWorkspace(t)
+	// Changed by hand while the status this program set had expired.
+	account := testAccount(fake, "work", slack.Profile{StatusText: "Focusing", StatusEmoji: ":headphones:"})
+	cfg := testConfig(account)
+
+	expired := meetingStatus
+	expired.StatusExpiration = time.Now().Add(-time.Minute).Unix()
+	lunch := SlackStatus{StatusText: "Lunch", StatusEmoji: ":pizza:"}
+	store.Accounts[account.Name] = accountState{Snapshot: &lunch, Written: &expired, State: presence.Meeting}
+
+	if _, err := setAccountState(context.Background(), cfg, account, pendingUpdate{signal: presence.Signal{State: presence.Meeting}, refresh: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setAccountState(context.Background(), cfg, account, pendingUpdate{signal: presence.Signal{State: presence.Available}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := profileOf(t, fake, account); got.StatusText != "Focusing" {
+		t.Errorf("profile = %+v, want the manual status kept", got)
+	}
+	if len(fake.Calls("users.profile.set")) != 0 {
+		t.Errorf("status was written over a manual change")
+	}
+}
+
+func TestSetStateBackToBackMeetings(t *testing.T) {
+	fake := newTestWorkspace(t)
+	vacation := slack.Profile{StatusText: "Vacation tomorrow", StatusEmoji: ":palm_tree:"}
+	account := testAccount(fake, "work", vacation)
+	cfg := testConfig(account)
+	ctx := context.Background()
+
+	for i := 1; i <= 2; i++ {
+		if !setState(ctx, cfg, presence.Signal{State: presence.Meeting}, false) {
+			t.Fatalf("meeting %d: could not set the meeting status", i)
+		}
+		if got := profileOf(t, fake, account); got.StatusText != meetingStatus.StatusText {
+			t.Fatalf("meeting %d: profile = %+v, want the meeting status", i, got)
+		}
+		if !setState(ctx, cfg, presence.Signal{State: presence.Available}, false) {
+			t.Fatalf("meeting %d: could not restore the status", i)
+		}
+		if got := profileOf(t, fake, account); got != vacation {
+			t.Errorf("after meeting %d, profile = %+v, want %+v", i, got, vacation)
+		}
+	}
+}
+
+func TestSetStateLeftoverMeetingStatus(t *testing.T) {
+	fake := newTestWorkspace(t)
+	// An earlier run crashed mid-meeting and its status is still shown.
+	account := testAccount(fake, "work", slack.Profile{StatusText: meetingStatus.StatusText, StatusEmoji: meetingStatus.StatusEmoji})
+	cfg := testConfig(account)
+	written := meetingStatus
+	store.Accounts[account.Name] = accountState{Written: &written, State: presence.Meeting}
+
+	if !setState(context.Background(), cfg, presence.Signal{State: presence.Meeting}, false) {
+		t.Fatal("setState failed")
+	}
+	if saved := store.account(account.Name); saved.Snapshot != nil {
+		t.Errorf("snapshot = %+v, want the leftover meeting status not kept", *saved.Snapshot)
+	}
+}
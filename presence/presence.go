@@ -0,0 +1,105 @@
+// Package presence picks the user's current state from the signals reported
+// by meeting detectors.
+package presence
+
+import (
+	"github.com/caitlinelfring/zoom-slack-status/detector"
+)
+
+// Built-in state names. Available is the state used when no other state is
+// active.
+const (
+	Available  = "available"
+	Meeting    = "meeting"
+	Camera     = "camera"
+	Presenting = "presenting"
+	Focus      = "focus"
+	Lunch      = "lunch"
+	Offline    = "offline"
+)
+
+// DefaultPriorities are the priorities of the built-in states. When several
+// states are active, the one with the highest priority wins.
+var DefaultPriorities = map[string]int{
+	Presenting: 60,
+	Camera:     50,
+	Meeting:    40,
+	Focus:      30,
+	Lunch:      20,
+	Offline:    10,
+	Available:  0,
+}
+
+// Priorities returns the default priorities with the given states added or
+// overridden.
+func Priorities(overrides map[string]int) map[string]int {
+	priorities := make(map[string]int, len(DefaultPriorities)+len(overrides))
+	for name, priority := range DefaultPriorities {
+		priorities[name] = priority
+	}
+	for name, priority := range overrides {
+		priorities[name] = priority
+	}
+	return priorities
+}
+
+// Signal is a state reported as active, with the detection that caused it.
+type Signal struct {
+	State   string
+	Meeting detector.MeetingState
+}
+
+// Transition is the result of a single engine step.
+type Transition struct {
+	From, To Signal
+	// Changed is set when the state, or details of it that appear in a
+	// status, are different from the previous step.
+	Changed bool
+}
+
+// Engine tracks the current state across detection passes.
+type Engine struct {
+	priorities map[string]int
+	current    Signal
+}
+
+// NewEngine creates an engine in the Available state.
+func NewEngine(priorities map[string]int) *Engine {
+	return &Engine{
+		priorities: priorities,
+		current:    Signal{State: Available},
+	}
+}
+
+// SetPriorities replaces the state priorities, e.g. after a config reload.
+func (e *Engine) SetPriorities(priorities map[string]int) {
+	e.priorities = priorities
+}
+
+// Current returns the state chosen by the last step.
+func (e *Engine) Current() Signal {
+	return e.current
+}
+
+// Step moves to the highest priority active state, or Available if there is
+// none. Signals for states without a priority are ignored.
+func (e *Engine) Step(signals []Signal) Transition {
+	next := Signal{State: Available}
+	best := e.priorities[Available]
+	for _, s := range signals {
+		priority, ok := e.priorities[s.State]
+		if ok && priority > best {
+			next, best = s, priority
+		}
+	}
+
+	t := Transition{From: e.current, To: next, Changed: changed(e.current, next)}
+	e.current = next
+	return t
+}
+
+func changed(prev, next Signal) bool {
+	return prev.State != next.State ||
+		prev.Meeting.Summary != next.Meeting.Summary ||
+		!prev.Meeting.End.Equal(next.Meeting.End)
+}
@@ -0,0 +1,97 @@
+package presence
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/detector"
+)
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals []Signal
+		want    string
+	}{
+		{name: "no signals", want: Available},
+		{name: "single", signals: []Signal{{State: Meeting}}, want: Meeting},
+		{name: "highest priority wins", signals: []Signal{{State: Lunch}, {State: Camera}, {State: Meeting}}, want: Camera},
+		{name: "order doesn't matter", signals: []Signal{{State: Meeting}, {State: Presenting}, {State: Camera}}, want: Presenting},
+		{name: "unknown state ignored", signals: []Signal{{State: "dentist"}}, want: Available},
+		{name: "unknown state next to a known one", signals: []Signal{{State: "dentist"}, {State: Focus}}, want: Focus},
+		{name: "explicit available", signals: []Signal{{State: Available}}, want: Available},
+	}
+	for _, tt := range tests {
+		e := NewEngine(Priorities(nil))
+		if got := e.Step(tt.signals); got.To.State != tt.want || e.Current().State != tt.want {
+			t.Errorf("%s: Step = %s, Current = %s, want %s", tt.name, got.To.State, e.Current().State, tt.want)
+		}
+	}
+}
+
+func TestStepKeepsDetection(t *testing.T) {
+	e := NewEngine(Priorities(nil))
+	meeting := detector.MeetingState{InMeeting: true, Detector: "calendar", Summary: "Standup"}
+	got := e.Step([]Signal{{State: Lunch}, {State: Meeting, Meeting: meeting}})
+	if !reflect.DeepEqual(got.To.Meeting, meeting) {
+		t.Errorf("To.Meeting = %+v, want %+v", got.To.Meeting, meeting)
+	}
+}
+
+func TestStepChanged(t *testing.T) {
+	end := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	meeting := func(summary string, end time.Time) []Signal {
+		return []Signal{{State: Meeting, Meeting: detector.MeetingState{InMeeting: true, Summary: summary, End: end}}}
+	}
+
+	e := NewEngine(Priorities(nil))
+	steps := []struct {
+		name    string
+		signals []Signal
+		changed bool
+	}{
+		{name: "still available", changed: false},
+		{name: "meeting starts", signals: meeting("Standup", end), changed: true},
+		{name: "same meeting", signals: meeting("Standup", end), changed: false},
+		{name: "same end in another zone", signals: meeting("Standup", end.In(time.FixedZone("EST", -5*3600))), changed: false},
+		{name: "summary changes", signals: meeting("Planning", end), changed: true},
+		{name: "end changes", signals: meeting("Planning", end.Add(30*time.Minute)), changed: true},
+		{name: "state changes", signals: []Signal{{State: Camera}}, changed: true},
+		{name: "meeting ends", changed: true},
+	}
+	for _, step := range steps {
+		from := e.Current()
+		got := e.Step(step.signals)
+		if got.Changed != step.changed {
+			t.Errorf("%s: Changed = %v, want %v", step.name, got.Changed, step.changed)
+		}
+		if !reflect.DeepEqual(got.From, from) {
+			t.Errorf("%s: From = %+v, want %+v", step.name, got.From, from)
+		}
+	}
+}
+
+func TestPriorities(t *testing.T) {
+	got := Priorities(map[string]int{"dentist": 45, Lunch: 55})
+	if got["dentist"] != 45 || got[Lunch] != 55 || got[Camera] != DefaultPriorities[Camera] {
+		t.Errorf("Priorities = %v", got)
+	}
+	if DefaultPriorities[Lunch] != 20 {
+		t.Error("Priorities modified the defaults")
+	}
+
+	e := NewEngine(got)
+	if s := e.Step([]Signal{{State: Meeting}, {State: "dentist"}}).To.State; s != "dentist" {
+		t.Errorf("Step = %s, want the added state to beat meeting", s)
+	}
+	if s := e.Step([]Signal{{State: Camera}, {State: Lunch}}).To.State; s != Lunch {
+		t.Errorf("Step = %s, want lunch raised above camera", s)
+	}
+
+	// A state below available never wins.
+	e.SetPriorities(Priorities(map[string]int{Offline: -1}))
+	if s := e.Step([]Signal{{State: Offline}}).To.State; s != Available {
+		t.Errorf("Step = %s, want available", s)
+	}
+}
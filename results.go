@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accountResult is the outcome of the last attempt to update an account.
+type accountResult struct {
+	State string
+	// Status is the last status written to Slack, nil if none was yet.
+	Status   *SlackStatus
+	Warnings []string
+	Err      error
+	Latency  time.Duration
+	At       time.Time
+
+	// LastErr is the most recent error, which may be from an earlier update,
+	// and LastErrAt when it happened.
+	LastErr   error
+	LastErrAt time.Time
+}
+
+// summary describes the result in a few words, for the menu.
+func (r accountResult) summary() string {
+	at := r.At.Format(time.Kitchen)
+	switch {
+	case r.Err != nil:
+		return "update failed at " + at
+	case len(r.Warnings) == 1:
+		return "updated with a warning at " + at
+	case len(r.Warnings) > 1:
+		return fmt.Sprintf("updated with %d warnings at %s", len(r.Warnings), at)
+	default:
+		return fmt.Sprintf("updated at %s in %s", at, r.Latency.Round(time.Millisecond))
+	}
+}
+
+// detail lists the error and warnings of the result.
+func (r accountResult) detail() string {
+	lines := make([]string, 0, len(r.Warnings)+1)
+	if r.Err != nil {
+		lines = append(lines, r.Err.Error())
+	}
+	lines = append(lines, r.Warnings...)
+	return strings.Join(lines, "\n")
+}
+
+// log prints the result of updating the named account.
+func (r accountResult) log(name string) {
+	if r.Err != nil {
+		fmt.Printf("Failed to update slack status for %s after %s: %s\n", name, r.Latency.Round(time.Millisecond), r.Err)
+	} else {
+		fmt.Printf("Updated slack status for %s to %s in %s\n", name, r.State, r.Latency.Round(time.Millisecond))
+	}
+	for _, w := range r.Warnings {
+		fmt.Printf("Warning for %s: %s\n", name, w)
+	}
+}
+
+// resultRegistry holds the last update result of each account by name.
+type resultRegistry struct {
+	mu       sync.Mutex
+	accounts map[string]accountResult
+}
+
+var results = &resultRegistry{accounts: map[string]accountResult{}}
+
+func (r *resultRegistry) get(name string) (accountResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res, ok := r.accounts[name]
+	return res, ok
+}
+
+func (r *resultRegistry) set(name string, res accountResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accounts[name] = res
+}
+
+// warningList collects the warnings raised while updating one account.
+type warningList struct {
+	mu   sync.Mutex
+	list []string
+}
+
+type warningsKey struct{}
+
+// withWarnings returns a context that collects the warnings passed to warn.
+func withWarnings(ctx context.Context) (context.Context, *warningList) {
+	w := &warningList{}
+	return context.WithValue(ctx, warningsKey{}, w), w
+}
+
+// warn adds a warning to the list collected by ctx, or prints it if ctx
+// doesn't collect warnings.
+func warn(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	w, ok := ctx.Value(warningsKey{}).(*warningList)
+	if !ok {
+		fmt.Println("Warning: " + msg)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.list = append(w.list, msg)
+}
+
+func (w *warningList) get() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.list...)
+}
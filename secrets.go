@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// secretCommandTimeout bounds commands run to look up a token.
+const secretCommandTimeout = 30 * time.Second
+
+// resolveToken returns the token an account's token setting refers to:
+//
+//	env:NAME                 the environment variable NAME
+//	file:PATH                the contents of a file
+//	cmd:COMMAND              the output of a shell command, e.g. cmd:pass show slack/work
+//	keyring:SERVICE/ACCOUNT  a password in the system keyring: the Secret
+//	                         Service (through secret-tool) on Linux, or the
+//	                         login keychain on macOS
+//
+// Anything else is taken to be the token itself.
+func resolveToken(ref string) (string, error) {
+	kind, value := splitTokenRef(ref)
+
+	var (
+		token string
+		err   error
+	)
+	switch kind {
+	case "":
+		return ref, nil
+	case "env":
+		var ok bool
+		if token, ok = os.LookupEnv(value); !ok {
+			err = fmt.Errorf("environment variable %s is not set", value)
+		}
+	case "file":
+		var path string
+		if path, err = homedir.Expand(value); err == nil {
+			var data []byte
+			data, err = ioutil.ReadFile(path)
+			token = string(data)
+		}
+	case "cmd":
+		token, err = runSecretCommand("sh", "-c", value)
+	case "keyring":
+		token, err = keyringLookup(value)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s token: %w", kind, err)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("%s token is empty", kind)
+	}
+	return token, nil
+}
+
+// splitTokenRef splits a token reference into its kind and value. The kind is
+// empty if the setting is a plain token.
+func splitTokenRef(ref string) (string, string) {
+	for _, kind := range []string{"env", "file", "cmd", "keyring"} {
+		if strings.HasPrefix(ref, kind+":") {
+			return kind, strings.TrimPrefix(ref, kind+":")
+		}
+	}
+	return "", ref
+}
+
+func keyringLookup(value string) (string, error) {
+	service, account, err := splitKeyringRef(value)
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "darwin" {
+		return runSecretCommand("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	}
+	return runSecretCommand("secret-tool", "lookup", "service", service, "account", account)
+}
+
+// keyringStore saves a token in the system keyring, where a keyring:
+// reference to value finds it. The token is passed on stdin, so it doesn't
+// show up in the process list.
+func keyringStore(value, token string) error {
+	service, account, err := splitKeyringRef(value)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "darwin" {
+		// security reads commands from stdin with -i, -U replaces an
+		// existing password.
+		command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", strconv.Quote(service), strconv.Quote(account), strconv.Quote(token))
+		_, err := runSecretCommandInput(command, "security", "-i")
+		return err
+	}
+	label := fmt.Sprintf("Slack token (%s/%s)", service, account)
+	_, err = runSecretCommandInput(token, "secret-tool", "store", "--label", label, "service", service, "account", account)
+	return err
+}
+
+// splitKeyringRef splits the SERVICE/ACCOUNT value of a keyring: reference.
+func splitKeyringRef(value string) (string, string, error) {
+	i := strings.LastIndex(value, "/")
+	if i <= 0 || i == len(value)-1 {
+		return "", "", fmt.Errorf("expected keyring:SERVICE/ACCOUNT, got %q", value)
+	}
+	return value[:i], value[i+1:], nil
+}
+
+func runSecretCommand(name string, args ...string) (string, error) {
+	return runSecretCommandInput("", name, args...)
+}
+
+// runSecretCommandInput runs a command with input on stdin and returns its
+// output.
+func runSecretCommandInput(input, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return string(out), nil
+}
+
+// redactToken hides a token so it can be logged. References to where a token
+// is kept are not secret and are shown as they are.
+func redactToken(token string) string {
+	if kind, _ := splitTokenRef(token); kind != "" {
+		return token
+	}
+	if token == "" {
+		return ""
+	}
+	// Keep the token type (xoxp-, xoxb-, ...) and the last few characters so
+	// tokens can still be told apart.
+	prefix := ""
+	if i := strings.Index(token, "-"); i > 0 && i <= 5 {
+		prefix = token[:i+1]
+	}
+	if len(token) < 16 {
+		return prefix + "[redacted]"
+	}
+	return prefix + "[redacted]" + token[len(token)-4:]
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSecretTool puts a secret-tool on PATH that keeps secrets in dir.
+func fakeSecretTool(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the keyring is only faked for secret-tool")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+dir=$(dirname "$0")
+case "$1" in
+store)
+	echo "$@" > "$dir/args"
+	cat > "$dir/$5.$7" ;;
+lookup)
+	cat "$dir/$3.$5" 2>/dev/null || exit 1 ;;
+esac
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestKeyringStore(t *testing.T) {
+	dir := fakeSecretTool(t)
+
+	if err := keyringStore("zoom-slack-status/work", "xoxp-1234"); err != nil {
+		t.Fatal(err)
+	}
+	args := readFile(t, filepath.Join(dir, "args"))
+	if strings.Contains(args, "xoxp-1234") {
+		t.Errorf("token passed as an argument: %s", args)
+	}
+	if want := "store --label Slack token (zoom-slack-status/work) service zoom-slack-status account work"; strings.TrimSpace(args) != want {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+
+	token, err := resolveToken("keyring:zoom-slack-status/work")
+	if err != nil || token != "xoxp-1234" {
+		t.Errorf("resolveToken = %q, %v", token, err)
+	}
+	if _, err := resolveToken("keyring:zoom-slack-status/home"); err == nil {
+		t.Error("expected an error for a missing keyring entry")
+	}
+}
+
+func TestKeyringRefs(t *testing.T) {
+	for _, value := range []string{"", "work", "/work", "zoom-slack-status/"} {
+		if err := keyringStore(value, "xoxp-1234"); err == nil {
+			t.Errorf("keyringStore(%q): expected an error", value)
+		}
+		if _, err := keyringLookup(value); err == nil {
+			t.Errorf("keyringLookup(%q): expected an error", value)
+		}
+	}
+	service, account, err := splitKeyringRef("my/service/work")
+	if err != nil || service != "my/service" || account != "work" {
+		t.Errorf("splitKeyringRef = %q, %q, %v", service, account, err)
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	setenv(t, "SLACK_TOKEN_TEST", " xoxp-env\n")
+	path := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(path, []byte("xoxp-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"xoxp-plain":           "xoxp-plain",
+		"env:SLACK_TOKEN_TEST": "xoxp-env",
+		"file:" + path:         "xoxp-file",
+		"cmd:echo xoxp-cmd":    "xoxp-cmd",
+	}
+	for ref, want := range tests {
+		if got, err := resolveToken(ref); err != nil || got != want {
+			t.Errorf("resolveToken(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"env:SLACK_TOKEN_MISSING", "file:/nonexistent", "cmd:exit 1", "cmd:true"} {
+		if _, err := resolveToken(ref); err == nil {
+			t.Errorf("resolveToken(%q): expected an error", ref)
+		}
+	}
+}
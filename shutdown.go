@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+)
+
+// shutdownTimeout bounds how long resetting the statuses may take when the
+// app stops.
+const shutdownTimeout = 15 * time.Second
+
+// exitResetFailed is the exit code when some statuses couldn't be reset.
+const exitResetFailed = 3
+
+var (
+	resetOnce sync.Once
+	resetOK   bool
+)
+
+// resetStatuses sets every account back to available when the app stops. It
+// only runs once, however the app is stopped, and reports whether every
+// account was reset.
+func resetStatuses() bool {
+	resetOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		resetOK = setState(ctx, config.get(), presence.Signal{State: presence.Available}, false)
+		if !resetOK {
+			fmt.Println("Some statuses could not be reset")
+		}
+	})
+	return resetOK
+}
+
+// notifyShutdown calls stop when the process gets SIGINT, SIGTERM or SIGHUP.
+// If shutting down takes too long, or another signal arrives, the process
+// exits without waiting for the statuses to be reset.
+func notifyShutdown(stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	go func() {
+		sig := <-signals
+		fmt.Printf("Received %s, resetting statuses and shutting down\n", sig)
+		stop()
+
+		select {
+		case sig = <-signals:
+			fmt.Printf("Received %s again, exiting now\n", sig)
+		case <-time.After(2 * shutdownTimeout):
+			fmt.Println("Timed out shutting down, exiting now")
+		}
+		os.Exit(exitResetFailed)
+	}()
+}
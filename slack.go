@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+)
+
+// newSlackClient creates the API client used for an account.
+func newSlackClient(token, baseURL string) *slack.Client {
+	client := slack.New(token)
+	if baseURL != "" {
+		client.BaseURL = baseURL
+	}
+	client.OnWarning = func(ctx context.Context, method, warning string) {
+		warn(ctx, "%s: %s", method, warning)
+	}
+	return client
+}
+
+// profile converts a status to the profile fields sent to Slack.
+func (s SlackStatus) profile() slack.Profile {
+	return slack.Profile{
+		StatusText:       s.StatusText,
+		StatusEmoji:      s.StatusEmoji,
+		StatusExpiration: s.StatusExpiration,
+	}
+}
+
+func statusFromProfile(p slack.Profile) SlackStatus {
+	return SlackStatus{
+		StatusText:       p.StatusText,
+		StatusEmoji:      p.StatusEmoji,
+		StatusExpiration: p.StatusExpiration,
+	}
+}
@@ -0,0 +1,371 @@
+// Package slack is a small client for the Slack Web API methods used to
+// manage a user's status. It retries rate limited and failed requests.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the base URL of the Slack Web API.
+const DefaultBaseURL = "https://slack.com/api/"
+
+// Profile holds the status fields of a user's profile.
+type Profile struct {
+	StatusText       string `json:"status_text"`
+	StatusEmoji      string `json:"status_emoji"`
+	StatusExpiration int64  `json:"status_expiration"`
+}
+
+// Error is returned when Slack responds with "ok": false.
+type Error struct {
+	Method string
+	Code   string
+}
+
+func (e *Error) Error() string {
+	return e.Method + ": " + e.Code
+}
+
+// IsError reports whether err is a Slack API error with the given code.
+func IsError(err error, code string) bool {
+	var slackErr *Error
+	return errors.As(err, &slackErr) && slackErr.Code == code
+}
+
+// response is the envelope shared by all Web API responses.
+type response struct {
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Warning string `json:"warning"`
+}
+
+// Client calls the Web API on behalf of a single user token.
+type Client struct {
+	Token string
+	// BaseURL is the API root, DefaultBaseURL if empty.
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// MaxRetries is how many times a request is retried after a rate limit,
+	// a 5xx response or a network error.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// retries. A Retry-After header from a 429 response takes precedence.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// OnWarning, if set, is called with warnings returned by the API. ctx is
+	// the context of the request that got the warning.
+	OnWarning func(ctx context.Context, method, warning string)
+}
+
+// New creates a client with default retry settings.
+func New(token string) *Client {
+	return &Client{
+		Token:      token,
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: http.DefaultClient,
+		MaxRetries: 3,
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// Identity is who a token belongs to, as reported by auth.test.
+type Identity struct {
+	URL    string `json:"url"`
+	Team   string `json:"team"`
+	TeamID string `json:"team_id"`
+	User   string `json:"user"`
+	UserID string `json:"user_id"`
+	// Scopes are the scopes granted to the token, from the X-OAuth-Scopes
+	// header. It is empty if Slack didn't send the header.
+	Scopes []string `json:"-"`
+}
+
+// HasScope reports whether the token was granted the scope. Tokens whose
+// scopes are unknown are assumed to have it.
+func (i Identity) HasScope(scope string) bool {
+	if len(i.Scopes) == 0 {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthTest checks the token with auth.test.
+func (c *Client) AuthTest(ctx context.Context) (Identity, error) {
+	var id Identity
+	header, err := c.do(ctx, "auth.test", &id, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "POST", c.url("auth.test"), nil)
+	})
+	if err != nil {
+		return Identity{}, err
+	}
+	for _, scope := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			id.Scopes = append(id.Scopes, scope)
+		}
+	}
+	return id, nil
+}
+
+// OAuthAccess is the result of exchanging an OAuth code for a user token.
+type OAuthAccess struct {
+	AuthedUser struct {
+		ID          string `json:"id"`
+		Scope       string `json:"scope"`
+		AccessToken string `json:"access_token"`
+	} `json:"authed_user"`
+	Team struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+}
+
+// OAuthV2Access exchanges the code from an OAuth v2 redirect for a token with
+// oauth.v2.access. The client doesn't need a token of its own.
+func (c *Client) OAuthV2Access(ctx context.Context, clientID, clientSecret, code, redirectURI string) (OAuthAccess, error) {
+	form := url.Values{
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}.Encode()
+
+	var access OAuthAccess
+	_, err := c.do(ctx, "oauth.v2.access", &access, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.url("oauth.v2.access"), strings.NewReader(form))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(clientID, clientSecret)
+		return req, nil
+	})
+	if err != nil {
+		return OAuthAccess{}, err
+	}
+	if access.AuthedUser.AccessToken == "" {
+		return OAuthAccess{}, fmt.Errorf("oauth.v2.access: no user token in response, was a user scope requested?")
+	}
+	return access, nil
+}
+
+// SetProfile sets the user's status with users.profile.set.
+func (c *Client) SetProfile(ctx context.Context, profile Profile) error {
+	body := struct {
+		Profile Profile `json:"profile"`
+	}{profile}
+	return c.post(ctx, "users.profile.set", body, nil)
+}
+
+// GetProfile returns the user's status with users.profile.get.
+func (c *Client) GetProfile(ctx context.Context) (Profile, error) {
+	var out struct {
+		Profile *Profile `json:"profile"`
+	}
+	if err := c.get(ctx, "users.profile.get", nil, &out); err != nil {
+		return Profile{}, err
+	}
+	if out.Profile == nil {
+		return Profile{}, fmt.Errorf("users.profile.get: no profile in response")
+	}
+	return *out.Profile, nil
+}
+
+// SetPresence sets the user's presence to "away" or "auto".
+func (c *Client) SetPresence(ctx context.Context, presence string) error {
+	return c.post(ctx, "users.setPresence", map[string]string{"presence": presence}, nil)
+}
+
+// SetSnooze turns on Do Not Disturb for the given number of minutes.
+func (c *Client) SetSnooze(ctx context.Context, minutes int) error {
+	return c.post(ctx, "dnd.setSnooze", map[string]int{"num_minutes": minutes}, nil)
+}
+
+// EndSnooze turns Do Not Disturb off. It is not an error if it wasn't on.
+func (c *Client) EndSnooze(ctx context.Context) error {
+	err := c.post(ctx, "dnd.endSnooze", struct{}{}, nil)
+	if IsError(err, "snooze_not_active") {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) post(ctx context.Context, method string, body, out interface{}) error {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, method, out, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.url(method), bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		return req, nil
+	})
+	return err
+}
+
+func (c *Client) get(ctx context.Context, method string, params url.Values, out interface{}) error {
+	u := c.url(method)
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	_, err := c.do(ctx, method, out, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", u, nil)
+	})
+	return err
+}
+
+func (c *Client) url(method string) string {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/" + method
+}
+
+// do sends the request built by newRequest, retrying rate limits and
+// transient failures, and decodes a successful response into out. It
+// returns the response headers.
+func (c *Client) do(ctx context.Context, method string, out interface{}, newRequest func() (*http.Request, error)) (http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if c.Token != "" && req.Header.Get("Authorization") == "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+
+		header, retryAfter, err := c.send(req, method, out)
+		if err == nil {
+			return header, nil
+		}
+		var retry *retryableError
+		if !errors.As(err, &retry) || attempt >= c.MaxRetries {
+			return nil, err
+		}
+
+		wait := retryAfter
+		if wait <= 0 {
+			wait = c.backoff(attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s: %w (last error: %s)", method, ctx.Err(), retry.err)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryableError marks a failure that may succeed if the request is sent
+// again.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// send makes a single attempt at a request. For rate limited requests it
+// also returns how long Slack asked to wait.
+func (c *Client) send(req *http.Request, method string, out interface{}) (http.Header, time.Duration, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if req.Context().Err() != nil {
+			return nil, 0, err
+		}
+		return nil, 0, &retryableError{err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		drain(resp.Body)
+		return nil, retryAfter(resp.Header), &retryableError{&Error{Method: method, Code: "ratelimited"}}
+	case resp.StatusCode >= 500:
+		drain(resp.Body)
+		return nil, 0, &retryableError{fmt.Errorf("%s: %s", method, resp.Status)}
+	case resp.StatusCode != http.StatusOK:
+		drain(resp.Body)
+		return nil, 0, fmt.Errorf("%s: %s", method, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, &retryableError{err}
+	}
+	var r response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, 0, fmt.Errorf("%s: decoding response: %w", method, err)
+	}
+	if r.Warning != "" && c.OnWarning != nil {
+		c.OnWarning(req.Context(), method, r.Warning)
+	}
+	if !r.Ok {
+		err := &Error{Method: method, Code: r.Error}
+		if r.Error == "ratelimited" {
+			return nil, retryAfter(resp.Header), &retryableError{err}
+		}
+		return nil, 0, err
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return nil, 0, fmt.Errorf("%s: decoding response: %w", method, err)
+		}
+	}
+	return resp.Header, 0, nil
+}
+
+// backoff returns the jittered delay before the given retry.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.MinBackoff
+	if d <= 0 {
+		d = 500 * time.Millisecond
+	}
+	for i := 0; i < attempt && (c.MaxBackoff <= 0 || d < c.MaxBackoff); i++ {
+		d *= 2
+	}
+	if c.MaxBackoff > 0 && d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	// Wait between half and all of the delay, so clients that failed
+	// together don't retry together.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses the Retry-After header, which Slack sends in seconds.
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// drain reads the rest of a body so the connection can be reused.
+func drain(body io.Reader) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, 64*1024))
+}
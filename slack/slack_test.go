@@ -0,0 +1,258 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// script serves the queued responses in order, then succeeds.
+type script struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	times     []time.Time
+}
+
+func (s *script) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.times = append(s.times, time.Now())
+	var respond func(w http.ResponseWriter)
+	if len(s.responses) > 0 {
+		respond, s.responses = s.responses[0], s.responses[1:]
+	}
+	s.mu.Unlock()
+
+	if respond == nil {
+		fmt.Fprint(w, `{"ok":true,"profile":{"status_text":"In a meeting"}}`)
+		return
+	}
+	respond(w)
+}
+
+// attempts returns when each request arrived.
+func (s *script) attempts() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.times...)
+}
+
+func status(code int, header ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func body(s string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) { fmt.Fprint(w, s) }
+}
+
+func newTestClient(t *testing.T, s *script) *Client {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	c := New("xoxp-test")
+	c.BaseURL = server.URL
+	c.MinBackoff = 20 * time.Millisecond
+	c.MaxBackoff = 40 * time.Millisecond
+	return c
+}
+
+func TestRetryAfter(t *testing.T) {
+	s := &script{responses: []func(http.ResponseWriter){
+		status(http.StatusTooManyRequests, "Retry-After", "1"),
+	}}
+	c := newTestClient(t, s)
+
+	start := time.Now()
+	profile, err := c.GetProfile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.StatusText != "In a meeting" {
+		t.Errorf("profile = %+v", profile)
+	}
+	// Retry-After takes precedence over the much shorter backoff.
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s Slack asked for", elapsed)
+	}
+	if n := len(s.attempts()); n != 2 {
+		t.Errorf("%d attempts, want 2", n)
+	}
+}
+
+func TestRatelimitedResponse(t *testing.T) {
+	s := &script{responses: []func(http.ResponseWriter){
+		body(`{"ok":false,"error":"ratelimited"}`),
+	}}
+	c := newTestClient(t, s)
+	if err := c.SetProfile(context.Background(), Profile{StatusText: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.attempts()); n != 2 {
+		t.Errorf("%d attempts, want 2", n)
+	}
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	s := &script{responses: []func(http.ResponseWriter){
+		status(http.StatusInternalServerError),
+		status(http.StatusBadGateway),
+		status(http.StatusServiceUnavailable),
+	}}
+	c := newTestClient(t, s)
+	if err := c.SetPresence(context.Background(), "away"); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := s.attempts()
+	if len(attempts) != 4 {
+		t.Fatalf("%d attempts, want 4", len(attempts))
+	}
+	// Each wait is at least half the backoff for that attempt.
+	for i, min := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond} {
+		if gap := attempts[i+1].Sub(attempts[i]); gap < min {
+			t.Errorf("retry %d after %s, want at least %s", i+1, gap, min)
+		}
+	}
+}
+
+// roundTripFunc fails requests without a server.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestRetryNetworkErrors(t *testing.T) {
+	s := &script{}
+	c := newTestClient(t, s)
+
+	failures := 2
+	transport := http.DefaultTransport
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("connection reset by peer")
+		}
+		return transport.RoundTrip(r)
+	})}
+	if err := c.SetSnooze(context.Background(), 30); err != nil {
+		t.Fatal(err)
+	}
+	if failures != 0 || len(s.attempts()) != 1 {
+		t.Errorf("%d failures left, %d requests reached the server", failures, len(s.attempts()))
+	}
+}
+
+func TestMaxRetries(t *testing.T) {
+	s := &script{}
+	for i := 0; i < 10; i++ {
+		s.responses = append(s.responses, status(http.StatusServiceUnavailable))
+	}
+	c := newTestClient(t, s)
+	c.MaxRetries = 2
+
+	err := c.SetProfile(context.Background(), Profile{})
+	if err == nil || err.Error() != "users.profile.set: 503 Service Unavailable" {
+		t.Errorf("err = %v, want the last 503", err)
+	}
+	if n := len(s.attempts()); n != 3 {
+		t.Errorf("%d attempts, want 3", n)
+	}
+
+	s = &script{responses: []func(http.ResponseWriter){status(http.StatusServiceUnavailable)}}
+	c = newTestClient(t, s)
+	c.MaxRetries = 0
+	if err := c.SetProfile(context.Background(), Profile{}); err == nil {
+		t.Error("expected an error")
+	}
+	if n := len(s.attempts()); n != 1 {
+		t.Errorf("%d attempts without retries, want 1", n)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	tests := map[string]struct {
+		respond func(http.ResponseWriter)
+		check   func(error) bool
+	}{
+		"api error": {
+			respond: body(`{"ok":false,"error":"invalid_auth"}`),
+			check:   func(err error) bool { return IsError(err, "invalid_auth") },
+		},
+		"client error": {
+			respond: status(http.StatusNotFound),
+			check:   func(err error) bool { return err != nil && err.Error() == "users.profile.get: 404 Not Found" },
+		},
+		"bad json": {
+			respond: body(`{"ok":`),
+			check:   func(err error) bool { return err != nil },
+		},
+	}
+	for name, tt := range tests {
+		s := &script{responses: []func(http.ResponseWriter){tt.respond}}
+		c := newTestClient(t, s)
+		if _, err := c.GetProfile(context.Background()); !tt.check(err) {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+		if n := len(s.attempts()); n != 1 {
+			t.Errorf("%s: %d attempts, want 1", name, n)
+		}
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	s := &script{responses: []func(http.ResponseWriter){
+		status(http.StatusTooManyRequests, "Retry-After", "60"),
+	}}
+	c := newTestClient(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.SetProfile(ctx, Profile{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %s despite the deadline", elapsed)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	s := &script{responses: []func(http.ResponseWriter){
+		body(`{"ok":true,"warning":"superfluous_charset","profile":{}}`),
+	}}
+	c := newTestClient(t, s)
+
+	type key struct{}
+	var got []string
+	c.OnWarning = func(ctx context.Context, method, warning string) {
+		got = append(got, fmt.Sprintf("%v %s %s", ctx.Value(key{}), method, warning))
+	}
+	ctx := context.WithValue(context.Background(), key{}, "req")
+	if _, err := c.GetProfile(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "req users.profile.get superfluous_charset" {
+		t.Errorf("warnings = %q", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{MinBackoff: 100 * time.Millisecond, MaxBackoff: 1 * time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := c.backoff(attempt); d < max/2 || d > max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", attempt, d, max/2, max)
+			}
+		}
+	}
+}
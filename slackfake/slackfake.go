@@ -0,0 +1,418 @@
+// Package slackfake is an in-memory stand-in for the parts of the Slack Web
+// API this program uses, for testing without network access.
+//
+//	srv := slackfake.New()
+//	defer srv.Close()
+//	srv.AddUser("xoxp-test", slackfake.User{ID: "U1", Team: "Example"})
+//	client := slack.New("xoxp-test")
+//	client.BaseURL = srv.APIURL()
+package slackfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+)
+
+// User is a fake user, identified by their token.
+type User struct {
+	ID     string
+	Name   string
+	TeamID string
+	Team   string
+	// Scopes granted to the token. If empty, every method is allowed.
+	Scopes []string
+
+	Profile  slack.Profile
+	Presence string
+	// SnoozeEnd is when Do Not Disturb ends, zero if it is off.
+	SnoozeEnd time.Time
+}
+
+// Failure makes a method fail instead of being handled.
+type Failure struct {
+	// Status is the HTTP status to respond with, 200 if zero.
+	Status int
+	// Error is the Slack error code for a 200 response.
+	Error string
+	// RetryAfter is sent as the Retry-After header, in seconds.
+	RetryAfter int
+	// Times is how many requests fail before the method works again. Zero
+	// means every request fails until Clear is called.
+	Times int
+}
+
+// Call records a request made to the server.
+type Call struct {
+	Method string
+	Token  string
+	// Status is the HTTP status of the response.
+	Status int
+	// Error is the Slack error code of the response, if any.
+	Error string
+}
+
+// scopes are the token scopes each method requires.
+var scopes = map[string]string{
+	"users.profile.get": "users.profile:read",
+	"users.profile.set": "users.profile:write",
+	"users.setPresence": "users:write",
+	"users.getPresence": "users:read",
+	"dnd.setSnooze":     "dnd:write",
+	"dnd.endSnooze":     "dnd:write",
+	"dnd.info":          "dnd:read",
+	"auth.test":         "",
+}
+
+// Server is a fake Slack Web API server.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]*User
+	codes    map[string]oauthCode
+	failures map[string]*Failure
+	calls    []Call
+	now      func() time.Time
+}
+
+// New starts a server with no users.
+func New() *Server {
+	s := &Server{
+		users:    map[string]*User{},
+		codes:    map[string]oauthCode{},
+		failures: map[string]*Failure{},
+		now:      time.Now,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// APIURL returns the base URL to give to a slack.Client.
+func (s *Server) APIURL() string {
+	return s.URL + "/api/"
+}
+
+// AddUser adds or replaces the user with the given token.
+func (s *Server) AddUser(token string, user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[token] = &user
+}
+
+// User returns the current state of the user with the given token.
+func (s *Server) User(token string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[token]
+	if !ok {
+		return User{}, false
+	}
+	return *user, true
+}
+
+// SetProfile changes a user's status as if they did it by hand.
+func (s *Server) SetProfile(token string, profile slack.Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[token]; ok {
+		user.Profile = profile
+	}
+}
+
+// oauthCode is an authorization code waiting to be exchanged.
+type oauthCode struct {
+	clientID, clientSecret string
+	token                  string
+	user                   User
+}
+
+// AddCode makes oauth.v2.access exchange code for token, for the given
+// client credentials. The user is added when the code is exchanged.
+func (s *Server) AddCode(code, clientID, clientSecret, token string, user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[code] = oauthCode{clientID: clientID, clientSecret: clientSecret, token: token, user: user}
+}
+
+// Fail makes the given method fail.
+func (s *Server) Fail(method string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = &failure
+}
+
+// Clear removes all failures.
+func (s *Server) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = map[string]*Failure{}
+}
+
+// Calls returns the requests made so far, optionally only for one method.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	params, err := readParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = params.get("token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	call := Call{Method: method, Token: token, Status: http.StatusOK}
+	defer func() { s.calls = append(s.calls, call) }()
+
+	if failure, ok := s.failures[method]; ok {
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				delete(s.failures, method)
+			}
+		}
+		if failure.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(failure.RetryAfter))
+		}
+		if failure.Status != 0 && failure.Status != http.StatusOK {
+			call.Status = failure.Status
+			w.WriteHeader(failure.Status)
+			return
+		}
+		call.Error = failure.Error
+		writeJSON(w, map[string]interface{}{"ok": false, "error": failure.Error})
+		return
+	}
+
+	if method == "oauth.v2.access" {
+		resp, errCode := s.exchange(r, params)
+		call.Error = errCode
+		if errCode != "" {
+			writeJSON(w, map[string]interface{}{"ok": false, "error": errCode})
+			return
+		}
+		resp["ok"] = true
+		writeJSON(w, resp)
+		return
+	}
+
+	required, known := scopes[method]
+	if !known {
+		call.Error = "unknown_method"
+		writeJSON(w, map[string]interface{}{"ok": false, "error": call.Error})
+		return
+	}
+	user, ok := s.users[token]
+	if !ok {
+		call.Error = "invalid_auth"
+		writeJSON(w, map[string]interface{}{"ok": false, "error": call.Error})
+		return
+	}
+	w.Header().Set("X-OAuth-Scopes", strings.Join(user.Scopes, ","))
+	if required != "" && len(user.Scopes) > 0 && !contains(user.Scopes, required) {
+		call.Error = "missing_scope"
+		writeJSON(w, map[string]interface{}{"ok": false, "error": call.Error, "needed": required})
+		return
+	}
+
+	resp, errCode := s.handle(method, user, params)
+	if errCode != "" {
+		call.Error = errCode
+		writeJSON(w, map[string]interface{}{"ok": false, "error": errCode})
+		return
+	}
+	resp["ok"] = true
+	writeJSON(w, resp)
+}
+
+// handle runs a method for a user. It returns the response fields or a
+// Slack error code. The caller holds s.mu.
+func (s *Server) handle(method string, user *User, params params) (map[string]interface{}, string) {
+	switch method {
+	case "auth.test":
+		return map[string]interface{}{
+			"url":     "https://" + strings.ToLower(user.Team) + ".slack.com/",
+			"team":    user.Team,
+			"user":    user.Name,
+			"team_id": user.TeamID,
+			"user_id": user.ID,
+		}, ""
+	case "users.profile.get":
+		return map[string]interface{}{"profile": user.Profile}, ""
+	case "users.profile.set":
+		var profile map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(params.get("profile")), &profile); err != nil {
+			return nil, "invalid_profile"
+		}
+		// Only the given fields change.
+		updated := user.Profile
+		for field, dst := range map[string]interface{}{
+			"status_text":       &updated.StatusText,
+			"status_emoji":      &updated.StatusEmoji,
+			"status_expiration": &updated.StatusExpiration,
+		} {
+			if raw, ok := profile[field]; ok {
+				if err := json.Unmarshal(raw, dst); err != nil {
+					return nil, "invalid_profile"
+				}
+			}
+		}
+		if len([]rune(updated.StatusText)) > 100 {
+			return nil, "too_long"
+		}
+		user.Profile = updated
+		return map[string]interface{}{"profile": user.Profile}, ""
+	case "users.setPresence":
+		presence := params.get("presence")
+		if presence != "away" && presence != "auto" {
+			return nil, "invalid_presence"
+		}
+		user.Presence = presence
+		return map[string]interface{}{}, ""
+	case "users.getPresence":
+		presence := user.Presence
+		if presence == "" {
+			presence = "auto"
+		}
+		return map[string]interface{}{"presence": presence}, ""
+	case "dnd.setSnooze":
+		minutes, err := strconv.Atoi(params.get("num_minutes"))
+		if err != nil || minutes <= 0 {
+			return nil, "invalid_arguments"
+		}
+		user.SnoozeEnd = s.now().Add(time.Duration(minutes) * time.Minute)
+		return map[string]interface{}{"snooze_enabled": true, "snooze_endtime": user.SnoozeEnd.Unix()}, ""
+	case "dnd.endSnooze":
+		if !user.SnoozeEnd.After(s.now()) {
+			return nil, "snooze_not_active"
+		}
+		user.SnoozeEnd = time.Time{}
+		return map[string]interface{}{"snooze_enabled": false}, ""
+	case "dnd.info":
+		snoozed := user.SnoozeEnd.After(s.now())
+		info := map[string]interface{}{"snooze_enabled": snoozed}
+		if snoozed {
+			info["snooze_endtime"] = user.SnoozeEnd.Unix()
+		}
+		return info, ""
+	}
+	return nil, "unknown_method"
+}
+
+// exchange handles oauth.v2.access. The caller holds s.mu.
+func (s *Server) exchange(r *http.Request, params params) (map[string]interface{}, string) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = params.get("client_id"), params.get("client_secret")
+	}
+	code, ok := s.codes[params.get("code")]
+	if !ok {
+		return nil, "invalid_code"
+	}
+	if code.clientID != clientID || code.clientSecret != clientSecret {
+		return nil, "invalid_client_id"
+	}
+	delete(s.codes, params.get("code"))
+
+	user := code.user
+	s.users[code.token] = &user
+	return map[string]interface{}{
+		"authed_user": map[string]interface{}{
+			"id":           user.ID,
+			"scope":        strings.Join(user.Scopes, ","),
+			"access_token": code.token,
+			"token_type":   "user",
+		},
+		"team": map[string]interface{}{"id": user.TeamID, "name": user.Team},
+	}, ""
+}
+
+// params holds the arguments of a request, which Slack accepts as a query
+// string, a form or a JSON body.
+type params map[string]string
+
+func (p params) get(key string) string {
+	return p[key]
+}
+
+func readParams(r *http.Request) (params, error) {
+	p := params{}
+	for key := range r.URL.Query() {
+		p[key] = r.URL.Query().Get(key)
+	}
+	if r.Body == nil {
+		return p, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %w", err)
+		}
+		for key, raw := range fields {
+			// Strings are unquoted, everything else is kept as JSON.
+			var str string
+			if json.Unmarshal(raw, &str) == nil {
+				p[key] = str
+			} else {
+				p[key] = string(raw)
+			}
+		}
+		return p, nil
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	for key := range form {
+		p[key] = form.Get(key)
+	}
+	return p, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
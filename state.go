@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+)
+
+// accountState is what is remembered about an account between runs.
+type accountState struct {
+	// Snapshot is the status the user had before a meeting started. It is
+	// restored when the user is available again.
+	Snapshot *SlackStatus `json:"snapshot,omitempty"`
+	// State is the state the account was last moved to.
+	State string `json:"state,omitempty"`
+	// Written is the status this program last set.
+	Written *SlackStatus `json:"written,omitempty"`
+	// Presence is the presence this program last set.
+	Presence string `json:"presence,omitempty"`
+	// Snoozed is set while this program has Do Not Disturb turned on.
+	Snoozed bool `json:"snoozed,omitempty"`
+}
+
+// held reports whether the account still shows something this program set
+// for a state other than available: its status, away presence or Do Not
+// Disturb.
+func (s accountState) held() bool {
+	return (s.State != "" && s.State != presence.Available) || s.Presence == "away" || s.Snoozed
+}
+
+// stateStore persists account state to disk, so that a status can still be
+// restored after a crash in the middle of a meeting.
+type stateStore struct {
+	mu       sync.Mutex
+	path     string
+	Accounts map[string]accountState `json:"accounts"`
+	// Override is the pause or forced state set from the menu or the API, so
+	// that it survives a restart.
+	Override *override `json:"override,omitempty"`
+}
+
+var store = &stateStore{Accounts: map[string]accountState{}}
+
+// loadState loads the saved state from the default location, or starts over
+// if it can't be read. Without a config directory the state is only kept in
+// memory.
+func loadState() {
+	path, err := defaultStatePath()
+	if err != nil {
+		fmt.Printf("Could not find where to save state, it will be lost on exit: %s\n", err)
+		store = &stateStore{Accounts: map[string]accountState{}}
+		return
+	}
+	if store, err = loadStateStore(path); err != nil {
+		fmt.Printf("Could not load saved state from %s, starting over: %s\n", path, err)
+		store = &stateStore{path: path, Accounts: map[string]accountState{}}
+	}
+}
+
+// defaultStatePath returns the state file in the user's config directory.
+func defaultStatePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "zoom-slack-status", "state.json"), nil
+}
+
+// loadStateStore reads the state file, if it exists.
+func loadStateStore(path string) (*stateStore, error) {
+	s := &stateStore{path: path, Accounts: map[string]accountState{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Accounts == nil {
+		s.Accounts = map[string]accountState{}
+	}
+	return s, nil
+}
+
+// account returns the stored state of the named account.
+func (s *stateStore) account(name string) accountState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Accounts[name]
+}
+
+// setAccount replaces the state of the named account and writes the store
+// to disk.
+func (s *stateStore) setAccount(name string, state accountState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Accounts[name] = state
+	return s.save()
+}
+
+// override returns the saved override, nil if there is none.
+func (s *stateStore) override() *override {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Override
+}
+
+// setOverride replaces the saved override and writes the store to disk.
+func (s *stateStore) setOverride(o *override) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Override = o
+	return s.save()
+}
+
+// save writes the store through a temporary file so a crash can't leave it
+// half written. The caller must hold s.mu.
+func (s *stateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
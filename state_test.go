@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets an environment variable for the rest of the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestLoadStateWithoutConfigDir(t *testing.T) {
+	setenv(t, "XDG_CONFIG_HOME", "")
+	setenv(t, "HOME", "")
+	if _, err := os.UserConfigDir(); err == nil {
+		t.Skip("the config directory can't be hidden on this platform")
+	}
+
+	loadState()
+	if store.path != "" {
+		t.Errorf("store path = %q, want an in-memory store", store.path)
+	}
+	if err := store.setAccount("work", accountState{State: "meeting"}); err != nil {
+		t.Errorf("setAccount: %v", err)
+	}
+	if got := store.account("work").State; got != "meeting" {
+		t.Errorf("State = %q, want meeting", got)
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	dir := t.TempDir()
+	setenv(t, "XDG_CONFIG_HOME", dir)
+	setenv(t, "HOME", dir)
+	path, err := defaultStatePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadState()
+	if store.path != path {
+		t.Fatalf("store path = %q, want %q", store.path, path)
+	}
+	if err := store.setAccount("work", accountState{State: "meeting", Snoozed: true}); err != nil {
+		t.Fatal(err)
+	}
+	loadState()
+	if got := store.account("work"); got.State != "meeting" || !got.Snoozed {
+		t.Errorf("reloaded state = %+v", got)
+	}
+
+	// A corrupt file starts over rather than failing.
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loadState()
+	if store.path != path || len(store.Accounts) != 0 {
+		t.Errorf("store = %q with %d accounts, want an empty store at %q", store.path, len(store.Accounts), path)
+	}
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("state directory mode = %v, %v", info, err)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+
+	"github.com/getlantern/systray"
+)
+
+// configMenu shows why the config file could not be reloaded.
+type configMenu struct {
+	item *systray.MenuItem
+}
+
+func newConfigMenu() *configMenu {
+	item := systray.AddMenuItem("", "")
+	item.Disable()
+	item.Hide()
+	return &configMenu{item: item}
+}
+
+// update shows the reload error, or hides the item if there is none.
+func (m *configMenu) update(err error) {
+	if err == nil {
+		m.item.Hide()
+		return
+	}
+	// Errors can span several lines, menu titles can't.
+	title := strings.SplitN(err.Error(), "\n", 2)[0]
+	m.item.SetTitle("⚠ Config not reloaded: " + title)
+	m.item.SetTooltip(err.Error() + "\n\nThe last good config is used until the file is fixed.")
+	m.item.Show()
+}
+
+// manualOverride is how long "Mark me in a meeting" and "Mark me free" last.
+const manualOverride = time.Hour
+
+// overrideMenu pauses automation, or marks the user busy or free whatever
+// the detectors say.
+type overrideMenu struct {
+	resume *systray.MenuItem
+}
+
+func newOverrideMenu() *overrideMenu {
+	pause := systray.AddMenuItem("Pause automation", "Leave your Slack statuses alone for a while")
+	pause15m := pause.AddSubMenuItem("For 15 minutes", "")
+	pause1h := pause.AddSubMenuItem("For 1 hour", "")
+	pauseDay := pause.AddSubMenuItem("Until tomorrow", "")
+	busy := systray.AddMenuItem("Mark me in a meeting", "Set the meeting status for an hour, whatever the detectors say")
+	free := systray.AddMenuItem("Mark me free", "Set the available status for an hour, whatever the detectors say")
+	resume := systray.AddMenuItem("Resume automation", "Go back to the detected state")
+	resume.Disable()
+
+	go func() {
+		for {
+			now := time.Now()
+			select {
+			case <-pause15m.ClickedCh:
+				control.setOverride(override{Paused: true, Until: now.Add(15 * time.Minute)})
+			case <-pause1h.ClickedCh:
+				control.setOverride(override{Paused: true, Until: now.Add(time.Hour)})
+			case <-pauseDay.ClickedCh:
+				y, m, d := now.Date()
+				control.setOverride(override{Paused: true, Until: time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())})
+			case <-busy.ClickedCh:
+				control.setOverride(override{State: presence.Meeting, Until: now.Add(manualOverride)})
+			case <-free.ClickedCh:
+				control.setOverride(override{State: presence.Available, Until: now.Add(manualOverride)})
+			case <-resume.ClickedCh:
+				control.clearOverride()
+			}
+		}
+	}()
+	return &overrideMenu{resume: resume}
+}
+
+// update enables "Resume automation" while there is something to resume.
+func (m *overrideMenu) update(overridden bool) {
+	if overridden {
+		m.resume.Enable()
+	} else {
+		m.resume.Disable()
+	}
+}
+
+// accountsMenu has a submenu for each configured account, showing whether it
+// works and how its last update went, with a checkbox to turn it off.
+type accountsMenu struct {
+	parent *systray.MenuItem
+	items  []*accountItem
+}
+
+func newAccountsMenu() *accountsMenu {
+	return &accountsMenu{parent: systray.AddMenuItem("Accounts", "Slack accounts")}
+}
+
+// accountItem is the submenu of one account.
+type accountItem struct {
+	item      *systray.MenuItem
+	workspace *systray.MenuItem
+	status    *systray.MenuItem
+	lastError *systray.MenuItem
+	enabled   *systray.MenuItem
+
+	mu sync.Mutex
+	// name is the account shown, items are reused when accounts change.
+	name string
+}
+
+func newAccountItem(parent *systray.MenuItem) *accountItem {
+	item := parent.AddSubMenuItem("", "")
+	a := &accountItem{
+		item:      item,
+		workspace: item.AddSubMenuItem("", ""),
+		status:    item.AddSubMenuItem("", "The status last set in this workspace"),
+		lastError: item.AddSubMenuItem("", ""),
+		enabled:   item.AddSubMenuItem("Enabled", "Update the status in this workspace"),
+	}
+	a.workspace.Disable()
+	a.status.Disable()
+	a.lastError.Disable()
+
+	go func() {
+		for range a.enabled.ClickedCh {
+			a.mu.Lock()
+			name := a.name
+			a.mu.Unlock()
+
+			enabled := !a.enabled.Checked()
+			if err := setAccountEnabled(name, enabled); err != nil {
+				fmt.Printf("Could not save whether %s is enabled: %s\n", name, err)
+				continue
+			}
+			// The config file is reloaded with the change; show it already.
+			if enabled {
+				fmt.Printf("Enabled %s\n", name)
+				a.enabled.Check()
+			} else {
+				fmt.Printf("Disabled %s\n", name)
+				a.enabled.Uncheck()
+			}
+		}
+	}()
+	return a
+}
+
+// update shows the health and last update result of each account. Menu
+// items can't be removed, so items left over from accounts that were removed
+// are hidden.
+func (m *accountsMenu) update(accounts []Account) {
+	broken, failing := 0, 0
+	for i, account := range accounts {
+		if i == len(m.items) {
+			m.items = append(m.items, newAccountItem(m.parent))
+		}
+		h := health.get(account.Name)
+		res, updated := results.get(account.Name)
+
+		switch {
+		case !account.enabled():
+		case h.broken():
+			broken++
+		case updated && res.Err != nil:
+			failing++
+		}
+		m.items[i].update(account, h, res, updated)
+	}
+	for _, a := range m.items[len(accounts):] {
+		a.item.Hide()
+	}
+
+	var problems []string
+	if broken > 0 {
+		problems = append(problems, fmt.Sprintf("%d broken", broken))
+	}
+	if failing > 0 {
+		problems = append(problems, fmt.Sprintf("%d failing", failing))
+	}
+	if len(problems) > 0 {
+		m.parent.SetTitle("Accounts: " + strings.Join(problems, ", "))
+	} else {
+		m.parent.SetTitle("Accounts")
+	}
+}
+
+func (a *accountItem) update(account Account, h accountHealth, res accountResult, updated bool) {
+	a.mu.Lock()
+	a.name = account.Name
+	a.mu.Unlock()
+
+	title := account.Name
+	if h.Team != "" {
+		title += " (" + h.Team + ")"
+	}
+	switch {
+	case !account.enabled():
+		title += ": disabled"
+	case h.broken():
+		title = "⚠ " + title + ": broken"
+	case updated:
+		if res.Err != nil {
+			title = "⚠ " + title
+		}
+		title += ": " + res.summary()
+	}
+	a.item.SetTitle(title)
+	a.item.SetTooltip(res.detail())
+
+	switch {
+	case h.broken():
+		a.workspace.SetTitle(fmt.Sprintf("⚠ %s", h.Err))
+		a.workspace.SetTooltip("Fix the token in the config file, this account is skipped until then")
+	case !h.Checked:
+		a.workspace.SetTitle("Workspace: not checked yet")
+		a.workspace.SetTooltip(fmt.Sprint(h.Err))
+	default:
+		a.workspace.SetTitle(fmt.Sprintf("Workspace: %s, signed in as %s", h.Team, h.UserID))
+		a.workspace.SetTooltip("")
+	}
+
+	switch {
+	case res.Status == nil:
+		a.status.SetTitle("Last status: none set yet")
+	case res.Status.empty():
+		a.status.SetTitle("Last status: cleared")
+	default:
+		a.status.SetTitle(strings.TrimSpace("Last status: " + res.Status.StatusEmoji + " " + res.Status.StatusText))
+	}
+
+	if res.LastErr != nil {
+		// Errors can span several lines, menu titles can't.
+		msg := strings.SplitN(res.LastErr.Error(), "\n", 2)[0]
+		a.lastError.SetTitle(fmt.Sprintf("Last error at %s: %s", res.LastErrAt.Format(time.Kitchen), msg))
+		a.lastError.SetTooltip(res.LastErr.Error())
+	} else {
+		a.lastError.SetTitle("No errors")
+		a.lastError.SetTooltip("")
+	}
+
+	if account.enabled() {
+		a.enabled.Check()
+	} else {
+		a.enabled.Uncheck()
+	}
+	a.item.Show()
+}
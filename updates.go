@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+)
+
+// pendingUpdate is the state an account should be moved to.
+type pendingUpdate struct {
+	signal presence.Signal
+	// refresh is set when only the status expiration needs pushing back.
+	refresh bool
+	// force is set for a state asked for explicitly, which is written even
+	// over a status set by hand.
+	force bool
+	seq   uint64
+}
+
+// updateQueue holds the latest wanted state of each account until it has been
+// applied. An update that fails stays queued and is retried on the next tick,
+// rather than being lost until the state changes again.
+type updateQueue struct {
+	mu      sync.Mutex
+	seq     uint64
+	pending map[string]pendingUpdate
+}
+
+var updates = &updateQueue{pending: map[string]pendingUpdate{}}
+
+// want queues an update for the account, replacing any older one. A refresh
+// doesn't replace a full update that is still pending for the same state.
+func (q *updateQueue) want(account string, signal presence.Signal, refresh bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if old, ok := q.pending[account]; ok && !old.refresh && old.signal.State == signal.State {
+		refresh = false
+	}
+	q.seq++
+	q.pending[account] = pendingUpdate{signal: signal, refresh: refresh, seq: q.seq}
+}
+
+// force queues an update that is written even over a status set by hand, and
+// that doesn't restore the status saved when a meeting started.
+func (q *updateQueue) force(account string, signal presence.Signal) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	q.pending[account] = pendingUpdate{signal: signal, force: true, seq: q.seq}
+}
+
+// get returns the pending update for the account.
+func (q *updateQueue) get(account string) (pendingUpdate, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	update, ok := q.pending[account]
+	return update, ok
+}
+
+// done removes an applied update, unless a newer one was queued meanwhile.
+func (q *updateQueue) done(account string, update pendingUpdate) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.pending[account].seq == update.seq {
+		delete(q.pending, account)
+	}
+}
+
+// drop removes any pending update for the account.
+func (q *updateQueue) drop(account string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, account)
+}
+
+// retain drops updates for accounts that are no longer configured.
+func (q *updateQueue) retain(accounts []Account) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keep := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		keep[account.Name] = true
+	}
+	for name := range q.pending {
+		if !keep[name] {
+			delete(q.pending, name)
+		}
+	}
+}
+
+// len returns the number of accounts with a pending update.
+func (q *updateQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+	"github.com/caitlinelfring/zoom-slack-status/slack"
+	"github.com/caitlinelfring/zoom-slack-status/slackfake"
+)
+
+func TestUpdateQueue(t *testing.T) {
+	q := &updateQueue{pending: map[string]pendingUpdate{}}
+	meeting := presence.Signal{State: presence.Meeting}
+
+	q.want("work", meeting, false)
+	first, _ := q.get("work")
+
+	// A refresh doesn't replace a full update that hasn't been applied.
+	q.want("work", meeting, true)
+	if update, _ := q.get("work"); update.refresh {
+		t.Error("a refresh replaced a pending full update")
+	}
+	// Finishing an older update keeps the newer one.
+	q.done("work", first)
+	if _, ok := q.get("work"); !ok {
+		t.Error("a newer update was dropped")
+	}
+
+	q.want("work", presence.Signal{State: presence.Available}, true)
+	if update, _ := q.get("work"); !update.refresh || update.signal.State != presence.Available {
+		t.Errorf("update = %+v, want a refresh to available", update)
+	}
+	update, _ := q.get("work")
+	q.done("work", update)
+	if q.len() != 0 {
+		t.Errorf("%d updates pending, want none", q.len())
+	}
+
+	q.want("work", meeting, false)
+	q.want("home", meeting, false)
+	q.retain([]Account{{Name: "home"}})
+	if _, ok := q.get("work"); ok || q.len() != 1 {
+		t.Error("retain kept an update for a removed account")
+	}
+}
+
+func TestUpdateQueueConvergesAfterFailure(t *testing.T) {
+	fake := newTestWorkspace(t)
+	work := testAccount(fake, "work", slack.Profile{})
+	work.Statuses[presence.Meeting].Presence = "away"
+	home := testAccount(fake, "home", slack.Profile{})
+	cfg := testConfig(work, home)
+	ctx := context.Background()
+
+	fake.Fail("users.profile.set", slackfake.Failure{Error: "internal_error", Times: 1})
+	meeting := presence.Signal{State: presence.Meeting}
+	if setState(ctx, cfg, meeting, false) {
+		t.Fatal("setState reported success with a failed account")
+	}
+	if updates.len() != 1 {
+		t.Fatalf("%d updates pending, want the failed one", updates.len())
+	}
+	if _, ok := updates.get("work"); !ok {
+		// Which account got the failure depends on scheduling.
+		work, home = home, work
+	}
+	if got := profileOf(t, fake, home); got.StatusText != meetingStatus.StatusText {
+		t.Errorf("%s profile = %+v, want the meeting status", home.Name, got)
+	}
+
+	// The next tick only asks for a refresh, but the failed full update is
+	// still applied in full.
+	if !setState(ctx, cfg, meeting, true) {
+		t.Fatal("retry failed")
+	}
+	if updates.len() != 0 {
+		t.Errorf("%d updates pending after the retry", updates.len())
+	}
+	for _, account := range []Account{work, home} {
+		if got := profileOf(t, fake, account); got.StatusText != meetingStatus.StatusText {
+			t.Errorf("%s profile = %+v, want the meeting status", account.Name, got)
+		}
+	}
+	if user, _ := fake.User(cfg.Accounts[0].Token); user.Presence != "away" {
+		t.Errorf("presence = %q, want away from the full update", user.Presence)
+	}
+	if res, _ := results.get(work.Name); res.Err != nil || res.LastErr == nil {
+		t.Errorf("result = %+v, want success with the earlier error kept", res)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// maxStatusTextLength is the longest status text Slack accepts, in
+// characters.
+const maxStatusTextLength = 100
+
+// emojiName matches an emoji name between colons, optionally followed by a
+// skin tone, e.g. ":wave::skin-tone-3:".
+var emojiName = regexp.MustCompile(`^:[^:\s]+:(:[^:\s]+:)?$`)
+
+// problem is something wrong with one setting of the config.
+type problem struct {
+	// Path is where the setting is in the config, e.g. accounts[0].token.
+	Path string
+	Msg  string
+}
+
+func (p problem) String() string {
+	return p.Path + ": " + p.Msg
+}
+
+// validationError lists everything wrong with a config.
+type validationError struct {
+	Problems []problem
+}
+
+func (e *validationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validate checks the config as read from the file, before defaults are
+// filled in. cfg.priorities must be set.
+func (c *Config) validate() []problem {
+	var probs []problem
+
+	if c.Interval <= 0 {
+		probs = append(probs, problem{"interval", fmt.Sprintf("must be more than 0, got %s", c.Interval)})
+	}
+	if c.StatusExpiry < 0 {
+		probs = append(probs, problem{"statusExpiry", fmt.Sprintf("must not be negative, got %s", c.StatusExpiry)})
+	}
+	if c.UpdateTimeout < 0 {
+		probs = append(probs, problem{"updateTimeout", fmt.Sprintf("must not be negative, got %s", c.UpdateTimeout)})
+	}
+
+	names := map[string]int{}
+	for i, account := range c.Accounts {
+		path := fmt.Sprintf("accounts[%d]", i)
+		probs = append(probs, account.validate(path, c.priorities)...)
+
+		if account.Name == "" {
+			continue
+		}
+		if first, ok := names[account.Name]; ok {
+			probs = append(probs, problem{path + ".name", fmt.Sprintf("%q is already used by accounts[%d]", account.Name, first)})
+		} else {
+			names[account.Name] = i
+		}
+	}
+
+	probs = append(probs, c.API.validate("api")...)
+
+	for i, d := range c.Detectors {
+		if _, ok := c.priorities[d.State]; d.State != "" && !ok {
+			probs = append(probs, problem{fmt.Sprintf("detectors[%d].state", i), unknownState(d.State, c.priorities)})
+		}
+	}
+	return probs
+}
+
+func (a Account) validate(path string, priorities map[string]int) []problem {
+	var probs []problem
+
+	if a.Name == "" {
+		probs = append(probs, problem{path + ".name", "must not be empty"})
+	}
+	switch kind, value := splitTokenRef(a.Token); {
+	case a.Token == "":
+		probs = append(probs, problem{path + ".token", "must not be empty"})
+	case kind != "" && value == "":
+		probs = append(probs, problem{path + ".token", fmt.Sprintf("%s: reference is missing what to read", kind)})
+	}
+
+	shorthands := []struct {
+		name   string
+		status *SlackStatus
+	}{
+		{"meetingStatus", a.MeetingStatus},
+		{"noMeetingStatus", a.NoMeetingStatus},
+		{"cameraStatus", a.CameraStatus},
+	}
+	for _, s := range shorthands {
+		if s.status != nil {
+			probs = append(probs, s.status.validate(path+"."+s.name)...)
+		}
+	}
+
+	states := make([]string, 0, len(a.Statuses))
+	for state := range a.Statuses {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		statusPath := path + ".statuses." + state
+		if _, ok := priorities[state]; !ok {
+			probs = append(probs, problem{statusPath, unknownState(state, priorities)})
+		}
+		if status := a.Statuses[state]; status != nil {
+			probs = append(probs, status.validate(statusPath)...)
+		}
+	}
+	return probs
+}
+
+func (a APIConfig) validate(path string) []problem {
+	if a.Listen == "" {
+		return nil
+	}
+
+	var probs []problem
+	switch kind, value := splitTokenRef(a.Token); {
+	case a.Token == "":
+		probs = append(probs, problem{path + ".token", "must be set when listen is"})
+	case kind != "" && value == "":
+		probs = append(probs, problem{path + ".token", fmt.Sprintf("%s: reference is missing what to read", kind)})
+	}
+
+	if strings.HasPrefix(a.Listen, "unix:") {
+		if a.Listen == "unix:" {
+			probs = append(probs, problem{path + ".listen", "unix: is missing the socket path"})
+		}
+		return probs
+	}
+	host, _, err := net.SplitHostPort(a.Listen)
+	if err != nil {
+		probs = append(probs, problem{path + ".listen", err.Error()})
+	} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		probs = append(probs, problem{path + ".listen", fmt.Sprintf("%q is not a loopback address, use e.g. 127.0.0.1:8977 or unix:PATH", a.Listen)})
+	}
+	return probs
+}
+
+func (s SlackStatus) validate(path string) []problem {
+	var probs []problem
+
+	if n := utf8.RuneCountInString(s.StatusText); n > maxStatusTextLength {
+		probs = append(probs, problem{path + ".status_text", fmt.Sprintf("is %d characters long, Slack allows at most %d", n, maxStatusTextLength)})
+	}
+	if s.StatusEmoji != "" && !emojiName.MatchString(s.StatusEmoji) {
+		probs = append(probs, problem{path + ".status_emoji", fmt.Sprintf("%q must be an emoji name between colons, like \":calendar:\"", s.StatusEmoji)})
+	}
+	switch s.Presence {
+	case "", "away", "auto":
+	default:
+		probs = append(probs, problem{path + ".presence", fmt.Sprintf("must be \"away\" or \"auto\", got %q", s.Presence)})
+	}
+	if s.DNDMinutes < 0 {
+		probs = append(probs, problem{path + ".dnd_minutes", fmt.Sprintf("must not be negative, got %d", s.DNDMinutes)})
+	}
+	return probs
+}
+
+func unknownState(state string, priorities map[string]int) string {
+	known := make([]string, 0, len(priorities))
+	for name := range priorities {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	return fmt.Sprintf("unknown state %q (known: %s; add it under states)", state, strings.Join(known, ", "))
+}
+
+// runValidate checks a config file, the one the app would use if none is
+// given, and prints every problem found. It doesn't contact Slack.
+func runValidate(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("expected at most one file, got %d arguments", len(args))
+	}
+	if len(args) == 1 {
+		viper.SetConfigFile(args[0])
+	}
+
+	_, err := readConfig()
+	var cfgErr *configError
+	var valErr *validationError
+	if !errors.As(err, &cfgErr) || !errors.As(err, &valErr) {
+		if err == nil {
+			fmt.Printf("%s: OK\n", viper.ConfigFileUsed())
+		}
+		return err
+	}
+
+	for _, p := range valErr.Problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cfgErr.File, p)
+	}
+	if n := len(valErr.Problems); n > 1 {
+		return fmt.Errorf("%d problems found", n)
+	}
+	return errors.New("1 problem found")
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caitlinelfring/zoom-slack-status/presence"
+	"github.com/spf13/viper"
+)
+
+const knownStates = "available, camera, focus, lunch, meeting, offline, presenting"
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Accounts: []Account{{Name: "work", Token: "xoxp-work"}},
+			Interval: time.Minute,
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []problem
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{
+			"zero interval",
+			func(c *Config) { c.Interval = 0 },
+			[]problem{{"interval", "must be more than 0, got 0s"}},
+		},
+		{
+			"negative interval",
+			func(c *Config) { c.Interval = -time.Second },
+			[]problem{{"interval", "must be more than 0, got -1s"}},
+		},
+		{
+			"negative durations",
+			func(c *Config) { c.StatusExpiry, c.UpdateTimeout = -time.Minute, -time.Second },
+			[]problem{
+				{"statusExpiry", "must not be negative, got -1m0s"},
+				{"updateTimeout", "must not be negative, got -1s"},
+			},
+		},
+		{
+			"empty name",
+			func(c *Config) { c.Accounts = append(c.Accounts, Account{Token: "xoxp-other"}) },
+			[]problem{{"accounts[1].name", "must not be empty"}},
+		},
+		{
+			"duplicate name",
+			func(c *Config) {
+				c.Accounts = append(c.Accounts, Account{Name: "home", Token: "xoxp-home"}, Account{Name: "work", Token: "xoxp-2"})
+			},
+			[]problem{{"accounts[2].name", `"work" is already used by accounts[0]`}},
+		},
+		{
+			"account problems",
+			func(c *Config) { c.Accounts[0].Token = "" },
+			[]problem{{"accounts[0].token", "must not be empty"}},
+		},
+		{
+			"api problems",
+			func(c *Config) { c.API = APIConfig{Listen: "127.0.0.1:8977"} },
+			[]problem{{"api.token", "must be set when listen is"}},
+		},
+		{
+			"unknown detector state",
+			func(c *Config) { c.Detectors = []DetectorConfig{{Type: "zoom"}, {Type: "zoom", State: "dnd"}} },
+			[]problem{{"detectors[1].state", `unknown state "dnd" (known: ` + knownStates + `; add it under states)`}},
+		},
+		{
+			"added state",
+			func(c *Config) {
+				c.States = map[string]int{"dnd": 70}
+				c.Detectors = []DetectorConfig{{Type: "zoom", State: "dnd"}}
+			},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+			c.priorities = presence.Priorities(c.States)
+			if got := c.validate(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		account Account
+		want    []problem
+	}{
+		{"valid", Account{Name: "work", Token: "xoxp-work"}, nil},
+		{
+			"empty",
+			Account{},
+			[]problem{
+				{"accounts[0].name", "must not be empty"},
+				{"accounts[0].token", "must not be empty"},
+			},
+		},
+		{
+			"token reference without a value",
+			Account{Name: "work", Token: "env:"},
+			[]problem{{"accounts[0].token", "env: reference is missing what to read"}},
+		},
+		{
+			"shorthand statuses",
+			Account{
+				Name:            "work",
+				Token:           "xoxp-work",
+				MeetingStatus:   &SlackStatus{StatusEmoji: "spiral_calendar_pad"},
+				NoMeetingStatus: &SlackStatus{Presence: "busy"},
+				CameraStatus:    &SlackStatus{DNDMinutes: -1},
+			},
+			[]problem{
+				{"accounts[0].meetingStatus.status_emoji", `"spiral_calendar_pad" must be an emoji name between colons, like ":calendar:"`},
+				{"accounts[0].noMeetingStatus.presence", `must be "away" or "auto", got "busy"`},
+				{"accounts[0].cameraStatus.dnd_minutes", "must not be negative, got -1"},
+			},
+		},
+		{
+			"unknown states",
+			Account{
+				Name:  "work",
+				Token: "xoxp-work",
+				Statuses: map[string]*SlackStatus{
+					"lunch":  {StatusEmoji: ":pizza:"},
+					"zoom":   {StatusEmoji: "zoom"},
+					"dinner": nil,
+				},
+			},
+			[]problem{
+				{"accounts[0].statuses.dinner", `unknown state "dinner" (known: ` + knownStates + `; add it under states)`},
+				{"accounts[0].statuses.zoom", `unknown state "zoom" (known: ` + knownStates + `; add it under states)`},
+				{"accounts[0].statuses.zoom.status_emoji", `"zoom" must be an emoji name between colons, like ":calendar:"`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.account.validate("accounts[0]", presence.DefaultPriorities)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlackStatusValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		status SlackStatus
+		want   []problem
+	}{
+		{"empty", SlackStatus{}, nil},
+		{"emoji", SlackStatus{StatusEmoji: ":calendar:"}, nil},
+		{"emoji with skin tone", SlackStatus{StatusEmoji: ":wave::skin-tone-3:"}, nil},
+		{"presence", SlackStatus{Presence: "away", DNDMinutes: 30}, nil},
+		{"100 characters", SlackStatus{StatusText: strings.Repeat("a", 100)}, nil},
+		{"100 multibyte characters", SlackStatus{StatusText: strings.Repeat("é", 100)}, nil},
+		{
+			"101 characters",
+			SlackStatus{StatusText: strings.Repeat("a", 101)},
+			[]problem{{"status.status_text", "is 101 characters long, Slack allows at most 100"}},
+		},
+		{
+			"emoji without colons",
+			SlackStatus{StatusEmoji: "calendar"},
+			[]problem{{"status.status_emoji", `"calendar" must be an emoji name between colons, like ":calendar:"`}},
+		},
+		{
+			"emoji missing a colon",
+			SlackStatus{StatusEmoji: ":calendar"},
+			[]problem{{"status.status_emoji", `":calendar" must be an emoji name between colons, like ":calendar:"`}},
+		},
+		{
+			"two emoji with a space",
+			SlackStatus{StatusEmoji: ":wave: :skin-tone-3:"},
+			[]problem{{"status.status_emoji", `":wave: :skin-tone-3:" must be an emoji name between colons, like ":calendar:"`}},
+		},
+		{
+			"unknown presence",
+			SlackStatus{Presence: "active"},
+			[]problem{{"status.presence", `must be "away" or "auto", got "active"`}},
+		},
+		{
+			"negative dnd",
+			SlackStatus{DNDMinutes: -5},
+			[]problem{{"status.dnd_minutes", "must not be negative, got -5"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.validate("status"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		api  APIConfig
+		want []problem
+	}{
+		{"off", APIConfig{}, nil},
+		{"off with a token", APIConfig{Token: "env:"}, nil},
+		{"loopback", APIConfig{Listen: "127.0.0.1:8977", Token: "secret"}, nil},
+		{"loopback v6", APIConfig{Listen: "[::1]:8977", Token: "secret"}, nil},
+		{"localhost", APIConfig{Listen: "localhost:8977", Token: "env:ZSS_API_TOKEN"}, nil},
+		{"unix socket", APIConfig{Listen: "unix:/run/user/1000/zss.sock", Token: "secret"}, nil},
+		{
+			"missing token",
+			APIConfig{Listen: "127.0.0.1:8977"},
+			[]problem{{"api.token", "must be set when listen is"}},
+		},
+		{
+			"token reference without a value",
+			APIConfig{Listen: "127.0.0.1:8977", Token: "file:"},
+			[]problem{{"api.token", "file: reference is missing what to read"}},
+		},
+		{
+			"not loopback",
+			APIConfig{Listen: "0.0.0.0:8977", Token: "secret"},
+			[]problem{{"api.listen", `"0.0.0.0:8977" is not a loopback address, use e.g. 127.0.0.1:8977 or unix:PATH`}},
+		},
+		{
+			"all interfaces",
+			APIConfig{Listen: ":8977", Token: "secret"},
+			[]problem{{"api.listen", `":8977" is not a loopback address, use e.g. 127.0.0.1:8977 or unix:PATH`}},
+		},
+		{
+			"host name",
+			APIConfig{Listen: "example.com:8977", Token: "secret"},
+			[]problem{{"api.listen", `"example.com:8977" is not a loopback address, use e.g. 127.0.0.1:8977 or unix:PATH`}},
+		},
+		{
+			"missing port",
+			APIConfig{Listen: "127.0.0.1", Token: "secret"},
+			[]problem{{"api.listen", "address 127.0.0.1: missing port in address"}},
+		},
+		{
+			"unix socket without a path",
+			APIConfig{Listen: "unix:", Token: "secret"},
+			[]problem{{"api.listen", "unix: is missing the socket path"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.api.validate("api"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadConfigValidationError(t *testing.T) {
+	useConfigFile(t, `accounts:
+  - name: work
+    token: xoxp-work
+    meetingStatus:
+      status_emoji: calendar
+interval: 0s
+`)
+
+	_, err := readConfig()
+	var valErr *validationError
+	if !errors.As(err, &valErr) {
+		t.Fatalf("readConfig() = %v, want a validation error", err)
+	}
+	want := []problem{
+		{"interval", "must be more than 0, got 0s"},
+		{"accounts[0].meetingStatus.status_emoji", `"calendar" must be an emoji name between colons, like ":calendar:"`},
+	}
+	if !reflect.DeepEqual(valErr.Problems, want) {
+		t.Errorf("problems = %q, want %q", valErr.Problems, want)
+	}
+}
+
+func TestNewConfigErrorParseLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".zoom-slack-status.yml")
+	contents := "accounts:\n  - name: work\n    token: [xoxp-work\ninterval: 1m\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigFile(path)
+
+	_, err := readConfig()
+	var cfgErr *configError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("readConfig() = %v, want a config error", err)
+	}
+	// The unclosed list is on line 3.
+	if cfgErr.File != path || cfgErr.Line != 3 {
+		t.Errorf("error at %s:%d, want %s:3", cfgErr.File, cfgErr.Line, path)
+	}
+	if want := path + ":3: yaml: did not find expected ',' or ']'"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}